@@ -0,0 +1,135 @@
+package sauron
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// This file contains our batch fetching of links
+
+// BatchOptions are the options for GetLinks
+type BatchOptions struct {
+	// Concurrency is the maximum number of URLs fetched at once. Defaults to 8.
+	Concurrency int
+
+	// Fetch is used to get each URL, if set. Defaults to GetLinkContext of the Client.
+	// This may be used to apply additional limits, such as a concurrency limit shared across batches.
+	Fetch func(ctx context.Context, urlPath string) (*Link, error)
+
+	// PerHostConcurrency is the maximum number of URLs fetched at once from a single host. Defaults to 2.
+	PerHostConcurrency int
+}
+
+// LinkResult is the result of getting a single URL with GetLinks
+type LinkResult struct {
+	Err  error
+	Link *Link
+	URL  string
+}
+
+// GetLinks will get the link information for the provided URLs concurrently using the DefaultClient
+func GetLinks(ctx context.Context, urls []string, opts *BatchOptions) []LinkResult {
+	return DefaultClient.GetLinks(ctx, urls, opts)
+}
+
+// GetLinks will get the link information for the provided URLs concurrently, limited by the Concurrency and PerHostConcurrency of the options
+// Results are returned in the same order as the provided URLs, each with its own link or error. Identical URLs are only fetched once, with each getting its own copy of the link.
+// If opts is nil, our default options are used.
+func (c *Client) GetLinks(ctx context.Context, urls []string, opts *BatchOptions) []LinkResult {
+	var options BatchOptions
+
+	if opts != nil {
+		options = *opts
+	}
+
+	if options.Concurrency <= 0 {
+		options.Concurrency = 8
+	}
+
+	if options.PerHostConcurrency <= 0 {
+		options.PerHostConcurrency = 2
+	}
+
+	if options.Fetch == nil {
+		options.Fetch = c.GetLinkContext
+	}
+
+	results := make([]LinkResult, len(urls))
+	indexesByURL := make(map[string][]int) // indexesByURL is the indexes of each unique URL, so duplicates are only fetched once
+	var uniqueURLs []string
+
+	for index, urlPath := range urls {
+		if _, exists := indexesByURL[urlPath]; !exists {
+			uniqueURLs = append(uniqueURLs, urlPath)
+		}
+
+		indexesByURL[urlPath] = append(indexesByURL[urlPath], index)
+	}
+
+	globalSlots := make(chan struct{}, options.Concurrency)
+	hostSlots := make(map[string]chan struct{})
+	var wg sync.WaitGroup
+
+	for _, urlPath := range uniqueURLs { // For each unique URL
+		host := batchHost(urlPath)
+		slots, exists := hostSlots[host]
+
+		if !exists { // First URL for this host
+			slots = make(chan struct{}, options.PerHostConcurrency)
+			hostSlots[host] = slots
+		}
+
+		wg.Add(1)
+
+		go func(urlPath string, hostSlots chan struct{}) {
+			defer wg.Done()
+
+			result := LinkResult{URL: urlPath}
+
+			if acquireErr := acquire(ctx, hostSlots); acquireErr != nil { // Wait for our host, before taking a global slot, so we do not hold one while waiting
+				result.Err = acquireErr
+			} else {
+				if acquireErr := acquire(ctx, globalSlots); acquireErr != nil {
+					result.Err = acquireErr
+				} else {
+					result.Link, result.Err = options.Fetch(ctx, urlPath)
+					<-globalSlots
+				}
+
+				<-hostSlots
+			}
+
+			for occurrence, index := range indexesByURL[urlPath] { // Set the result of each occurrence of this URL
+				results[index] = result
+
+				if occurrence > 0 { // Copy for duplicates, so callers may modify their link without affecting others
+					results[index].Link = result.Link.Copy()
+				}
+			}
+		}(urlPath, slots)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// acquire will wait for a free slot, unless the context is done first
+func acquire(ctx context.Context, slots chan struct{}) error {
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// batchHost will get the host used for per-host limits of the provided URL
+func batchHost(urlPath string) string {
+	if u, parseErr := url.Parse(urlPath); parseErr == nil {
+		return strings.ToLower(u.Hostname())
+	}
+
+	return ""
+}
@@ -0,0 +1,194 @@
+package sauron
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// This file contains our Cache interface and policy, used by GetLink to avoid refetching pages
+
+// Cache is a store of GetLink results by normalized URL
+// Implementations must be safe for concurrent use. Entries are stored as copies, so implementations do not need to copy them.
+type Cache interface {
+	// Delete will remove the entry for the key, if any
+	Delete(key string)
+
+	// Get will get the entry for the key, if any
+	// Implementations may remove entries which are past their StaleUntil rather than returning them.
+	Get(key string) (*CacheEntry, bool)
+
+	// Set will store the entry for the key, replacing any existing entry
+	Set(key string, entry *CacheEntry)
+}
+
+// CacheEntry is a cached result of GetLink, which is either a link or an error
+type CacheEntry struct {
+	Err error
+
+	// Expires is when the entry stops being fresh
+	Expires time.Time
+
+	Link *Link
+
+	// StaleUntil is when the entry may no longer be used. Between Expires and StaleUntil, the entry is used while it is refreshed in the background.
+	StaleUntil time.Time
+}
+
+// CachePolicy is how long results are cached for
+type CachePolicy struct {
+	// ErrorTTL is how long failures are cached for, such as a page returning 404. Zero disables caching of failures.
+	// Failures from the caller's context being cancelled are never cached.
+	ErrorTTL time.Duration
+
+	// HostTTLs is how long links are cached for on specific hosts, keyed by host. Subdomains of the host also match.
+	HostTTLs map[string]time.Duration
+
+	// StaleTTL is how long after expiring an entry may still be used while it is refreshed in the background. Zero disables stale-while-revalidate.
+	StaleTTL time.Duration
+
+	// TTL is how long links are cached for if no other rule applies. Zero disables caching of links.
+	TTL time.Duration
+
+	// TTLFunc is used to get how long a link is cached for, if set. If it returns zero, HostTTLs and TTL are used. It is not called without a link.
+	// This may be used for TTLs based on what a parser found, such as whether a Twitch link is a clip or a live channel.
+	TTLFunc func(link *Link) time.Duration
+}
+
+// NewCachePolicy will create a new CachePolicy with our default TTLs
+// Links are cached for an hour, Twitch channels for a minute so live status stays current, and YouTube and Twitch clips for a day.
+func NewCachePolicy() *CachePolicy {
+	return &CachePolicy{
+		ErrorTTL: 5 * time.Minute,
+		HostTTLs: map[string]time.Duration{
+			"twitch.tv":   time.Minute,
+			"youtu.be":    24 * time.Hour,
+			"youtube.com": 24 * time.Hour,
+		},
+		StaleTTL: time.Hour,
+		TTL:      time.Hour,
+		TTLFunc: func(link *Link) time.Duration {
+			if link == nil { // No link to base our TTL on
+				return 0
+			}
+
+			if link.Extras["IsClip"] == "true" { // Twitch clips do not change
+				return 24 * time.Hour
+			}
+
+			if link.Extras["IsPlaylist"] == "true" { // YouTube playlists change more often than videos
+				return time.Hour
+			}
+
+			return 0
+		},
+	}
+}
+
+// linkTTL will get how long the link for the key should be cached for
+func (policy *CachePolicy) linkTTL(key string, link *Link) time.Duration {
+	if policy.TTLFunc != nil && link != nil { // Parsers may return no link without an error
+		if ttl := policy.TTLFunc(link); ttl > 0 {
+			return ttl
+		}
+	}
+
+	ttl := policy.TTL
+
+	if u, parseErr := url.Parse(key); parseErr == nil {
+		host := strings.ToLower(u.Hostname())
+		var matchedHost string
+
+		for ruleHost, hostTTL := range policy.HostTTLs { // Use the most specific matching host
+			if hostMatches(host, ruleHost) && len(ruleHost) > len(matchedHost) {
+				matchedHost = ruleHost
+				ttl = hostTTL
+			}
+		}
+	}
+
+	return ttl
+}
+
+// result will get a copy of the entry's link for the provided URL, or the entry's error
+func (entry *CacheEntry) result(urlPath string) (*Link, error) {
+	link := entry.Link.Copy()
+
+	if link != nil { // Our link may have been fetched for a different form of the same URL
+		link.URI = urlPath
+	}
+
+	return link, entry.Err
+}
+
+// cachedLink will get the cached result for the key, if the entry is fresh or may be used while stale
+// Stale entries are refreshed in the background.
+func (c *Client) cachedLink(key, urlPath string) (link *Link, linkErr error, found bool) {
+	entry, found := c.Cache.Get(key)
+
+	if !found {
+		return
+	}
+
+	now := time.Now()
+
+	if now.Before(entry.Expires) { // Fresh
+		link, linkErr = entry.result(urlPath)
+		return
+	}
+
+	if now.Before(entry.StaleUntil) { // Stale, but may be used while we refresh it
+		go c.inflight.do(context.Background(), key, func(fetchCtx context.Context) (*Link, error) { // Shared with any other refresh or request for the key
+			return c.fetch(fetchCtx, key, urlPath)
+		})
+
+		link, linkErr = entry.result(urlPath)
+		return
+	}
+
+	return nil, nil, false
+}
+
+// fetch will get the link for the provided URL, storing the result in our Cache
+func (c *Client) fetch(ctx context.Context, key, urlPath string) (*Link, error) {
+	link, linkErr := c.getLink(ctx, urlPath)
+
+	if c.Cache == nil { // Not caching
+		return link, linkErr
+	}
+
+	policy := c.CachePolicy
+
+	if policy == nil { // No policy set, so use our defaults
+		policy = NewCachePolicy()
+	}
+
+	var ttl time.Duration
+
+	if linkErr == nil && link != nil { // Only cache links, as a parser returning no link without an error may find one later
+		ttl = policy.linkTTL(key, link)
+	} else if !errors.Is(linkErr, context.Canceled) && !errors.Is(linkErr, context.DeadlineExceeded) { // Do not cache the caller giving up
+		ttl = policy.ErrorTTL
+	}
+
+	if ttl > 0 {
+		now := time.Now()
+		c.Cache.Set(key, &CacheEntry{Err: linkErr, Expires: now.Add(ttl), Link: link.Copy(), StaleUntil: now.Add(ttl + policy.StaleTTL)})
+	}
+
+	return link, linkErr
+}
+
+// requestKey will get the key used for coalescing and caching requests for the provided URL
+// This is the normalized URL, so URLs which only differ by tracking parameters and the like share a key.
+func (c *Client) requestKey(urlPath string) string {
+	if c.Normalizer != nil {
+		if normalized, normalizeErr := c.Normalizer.NormalizeString(urlPath); normalizeErr == nil {
+			return normalized
+		}
+	}
+
+	return urlPath
+}
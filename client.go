@@ -0,0 +1,480 @@
+package sauron
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// This file contains our Client, an instance of Sauron with its own parsers, HTTP client, request headers and options
+
+// Client is an instance of Sauron with its own registered parsers, HTTP client, request headers and options
+// Clients should be created with NewClient. Parsers may be registered and unregistered while requests are in flight.
+type Client struct {
+	// Cache is used to store and reuse the results of GetLink, if set. See NewMemoryCache and NewDiskCache.
+	Cache Cache
+
+	// CachePolicy is how long results are stored in our Cache. Defaults to NewCachePolicy.
+	CachePolicy *CachePolicy
+
+	// CoalesceRequests indicates concurrent requests for the same normalized URL should share a single fetch and parse. Enabled by default.
+	// The shared fetch is only cancelled once every caller waiting for it has given up. Parsers receive the context values of the caller which started the fetch.
+	CoalesceRequests bool
+
+	// ExpandShorteners indicates URLs from our Shorteners should be expanded to their destination before being fetched.
+	// The destination is then used for choosing a parser, so a shortened link to a Twitch clip is handled by our Twitch parser.
+	ExpandShorteners bool
+
+	// FetchOEmbed indicates HTML pages should be enriched with oEmbed, using our OEmbedProviders or otherwise the oEmbed endpoint declared by the page.
+	// This requires an additional request per page, so is disabled by default. The response is available as Link.OEmbed.
+	FetchOEmbed bool
+
+	// FetchManifestIcons indicates Primitive should fetch the web app manifest of pages which declare one, adding its icons to Link.Icons.
+	// This requires an additional request per page, so is disabled by default.
+	FetchManifestIcons bool
+
+	// ForcedParser is used for all HTML pages instead of our registered parsers and Primitive, if set.
+	// This is mostly useful for debugging how a specific parser handles a page.
+	ForcedParser ContextLinkParser
+
+	// HeadOnlyPrimitive indicates pages handled by Primitive should stop being read once </head> is reached.
+	// This saves bandwidth on large pages, but Primitive will no longer fall back to the first image in the page body.
+	HeadOnlyPrimitive bool
+
+	// HTTPClient is the client used for page fetching as well as any secondary requests made by parsers
+	// Parsers should get this client via HTTPClientFromContext. Use SetHTTPClient or SetTransport to change it.
+	HTTPClient *http.Client
+
+	// MaxBodySize is the maximum number of bytes to read from a page. Pages exceeding this return ErrBodyTooLarge.
+	// Defaults to 10 MiB. A value of 0 or less disables the limit.
+	MaxBodySize int64
+
+	// MaxShortenerHops is the maximum number of redirects followed when expanding a shortened URL. Defaults to 10.
+	MaxShortenerHops int
+
+	// MetaImageNames is an array of meta names or properties commonly associated with site images, in order of precedence
+	MetaImageNames []string
+
+	// NormalizeURLs indicates URLs provided to GetLink should be normalized with our Normalizer before being fetched, such as stripping tracking parameters
+	NormalizeURLs bool
+
+	// Normalizer is the rules used by Normalize, as well as by GetLink if NormalizeURLs is enabled. Defaults to NewNormalizer.
+	Normalizer *Normalizer
+
+	// OEmbedProviders is the oEmbed providers used when FetchOEmbed is enabled. Defaults to DefaultOEmbedProviders.
+	OEmbedProviders []OEmbedProvider
+
+	// RequestLanguage is the desired language to request a page with. Defaults to en-US / en
+	RequestLanguage string
+
+	// Shorteners is the hosts of URL shorteners expanded by Expand, as well as by GetLink if ExpandShorteners is enabled. Subdomains also match.
+	Shorteners []string
+
+	// UserAgent is the desired User Agent to report to a page via request. Defaults to Sauron Bot $VERSION (e.g. Sauron Bot 0.1)
+	UserAgent string
+
+	// YoutubeQueriesToExtras is query info to extra metadata
+	YoutubeQueriesToExtras map[string]string
+
+	inflight  *inflightGroup    // inflight is our fetches in flight, for coalescing requests
+	registry  *parserRegistry   // registry is our registry of hostnames to parsers
+	rewriters *rewriterRegistry // rewriters is our pipeline of URL rewriters
+}
+
+// NewClient will create a new Client with our internal parsers registered and our default options
+func NewClient() (c *Client) {
+	c = &Client{
+		CachePolicy:      NewCachePolicy(),
+		CoalesceRequests: true,
+		HTTPClient: &http.Client{
+			Timeout: time.Second * 15, // 15 seconds
+		},
+		MaxBodySize:      10 << 20, // 10 MiB
+		MaxShortenerHops: 10,
+		MetaImageNames:   []string{"og:image", "og:image:url", "twitter:image", "twitter:image:src"},
+		Normalizer:       NewNormalizer(),
+		OEmbedProviders:  DefaultOEmbedProviders(),
+		RequestLanguage:  "en-US,en;q=0.5",
+		Shorteners: []string{
+			"amzn.to", "bit.ly", "buff.ly", "cutt.ly", "dlvr.it", "fb.me", "goo.gl", "is.gd", "lnkd.in", "ow.ly", "rebrand.ly", "redd.it", "t.co", "t.ly", "tinyurl.com", "trib.al", "youtu.be",
+		},
+		UserAgent: "Sauron Bot 0.1",
+		YoutubeQueriesToExtras: map[string]string{
+			"i":    "Index",
+			"list": "Playlist",
+			"t":    "Time",
+			"v":    "Video",
+		},
+		inflight: newInflightGroup(),
+	}
+
+	c.rewriters = newRewriterRegistry(c.HasOverridden)
+
+	reddit := registeredParser{parser: c.RedditContext}
+	twitch := registeredParser{parser: c.TwitchContext, headOnly: true} // Our Twitch parser uses GQL rather than the document
+	youtube := registeredParser{parser: c.YoutubeContext}
+
+	c.registry = newParserRegistry(map[string]registeredParser{
+		"old.reddit.com":  reddit,
+		"reddit.com":      reddit,
+		"clips.twitch.tv": twitch,
+		"twitch.tv":       twitch,
+		"www.twitch.tv":   twitch,
+		"youtu.be":        youtube,
+		"youtube.com":     youtube,
+	})
+
+	return
+}
+
+// EnableSafeDialer will prevent this Client from connecting to private, loopback, link-local, multicast and unspecified addresses
+// This applies to every request, including redirects and secondary requests made by parsers. Refused connections return an error matching ErrBlockedAddress.
+// Each item in the allowlist may be a CIDR (10.1.2.0/24), IP (10.1.2.3) or hostname (internal.example.com) which is permitted regardless.
+// If the current transport is an *http.Transport, its settings are retained, but proxies will no longer be used.
+// This should be enabled for any Client fetching URLs on behalf of others, such as behind a publicly accessible oembed.Handler or server.Handler,
+// so callers can not use it to reach internal services.
+func (c *Client) EnableSafeDialer(allowlist ...string) error {
+	safeDialer, dialerErr := NewSafeDialer(allowlist...)
+
+	if dialerErr != nil {
+		return dialerErr
+	}
+
+	c.SetTransport(safeDialer.Transport(c.HTTPClient.Transport))
+	return nil
+}
+
+// fetchResource will fetch the provided URL for a secondary request such as a web app manifest or oEmbed response, reading up to maxSize bytes
+// The content is returned along with the final URL after any redirects, which relative URLs in the content should be resolved against.
+func (c *Client) fetchResource(ctx context.Context, rawURL string, maxSize int64) (content []byte, finalURL *url.URL, fetchErr error) {
+	u, parseErr := url.Parse(rawURL)
+
+	if parseErr != nil {
+		return nil, nil, parseErr
+	}
+
+	response, getErr := c.HTTPClient.Do(c.NewRequest(ctx, u))
+
+	if getErr != nil {
+		return nil, nil, &RequestError{Err: getErr, URL: rawURL}
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK { // Status not OK
+		return nil, nil, &HTTPStatusError{StatusCode: response.StatusCode, URL: rawURL}
+	}
+
+	content, readErr := readPage(response.Body, maxSize, false)
+
+	if readErr == ErrBodyTooLarge {
+		return nil, nil, readErr
+	} else if readErr != nil {
+		return nil, nil, &RequestError{Err: readErr, URL: rawURL}
+	}
+
+	return content, response.Request.URL, nil
+}
+
+// ForceRegister will force register a LinkParser against the provided hostname
+// This is identical to calling Unregister then Register.
+func (c *Client) ForceRegister(hostName string, parser LinkParser, options ...ParserOption) error {
+	return c.ForceRegisterContext(hostName, WithContext(parser), options...)
+}
+
+// ForceRegisterContext will force register a ContextLinkParser against the provided hostname
+// This is identical to calling Unregister then RegisterContext, but is done atomically.
+func (c *Client) ForceRegisterContext(hostName string, parser ContextLinkParser, options ...ParserOption) error {
+	return c.registry.register(hostName, newRegisteredParser(parser, options), true)
+}
+
+// ForceRegisterRewriter will force register a URLRewriter with the provided name
+// If a rewriter already exists with this name, such as one of our internal rewriters, it is replaced in its current position in the pipeline.
+func (c *Client) ForceRegisterRewriter(name string, rewriter URLRewriter) error {
+	return c.rewriters.register(name, rewriter, true)
+}
+
+// GetLink will get the link information for the provided url
+func (c *Client) GetLink(urlPath string) (*Link, error) {
+	return c.GetLinkContext(context.Background(), urlPath)
+}
+
+// GetLinkContext will get the link information for the provided url
+// The provided context is used for the page fetch, reading of the page and any secondary requests made by parsers.
+// If the context is cancelled or its deadline is exceeded, the returned error will match the context's error with errors.Is.
+//
+// Errors from fetching the page are a *RequestError, *HTTPStatusError or *UnsupportedContentError, which match
+// ErrNoResponse (and ErrTimeout for timeouts), ErrPageNotAccessible and ErrUnsupportedContent respectively.
+//
+// If CoalesceRequests is enabled, concurrent requests for the same normalized URL share a single fetch, with each caller getting its own copy of the link.
+// If a Cache is set, cached results are returned according to our CachePolicy, including cached failures.
+func (c *Client) GetLinkContext(ctx context.Context, urlPath string) (*Link, error) {
+	key := c.requestKey(urlPath)
+
+	if c.Cache != nil { // If we have a cache, use any fresh or usable stale result
+		if link, linkErr, found := c.cachedLink(key, urlPath); found {
+			return link, linkErr
+		}
+	}
+
+	if !c.CoalesceRequests { // Fetch for this caller alone
+		return c.fetch(ctx, key, urlPath)
+	}
+
+	link, linkErr := c.inflight.do(ctx, key, func(fetchCtx context.Context) (*Link, error) {
+		return c.fetch(fetchCtx, key, urlPath)
+	})
+
+	if linkErr != nil && linkErr == ctx.Err() { // Gave up waiting, so match the error of an uncoalesced request
+		return nil, &RequestError{Err: linkErr, URL: urlPath}
+	}
+
+	if link != nil { // Our link may have been fetched for a different form of the same URL
+		link.URI = urlPath
+	}
+
+	return link, linkErr
+}
+
+// getLink will fetch and parse the provided url
+func (c *Client) getLink(ctx context.Context, urlPath string) (link *Link, parseErr error) {
+	var u *url.URL              // url struct to pass to parsers
+	var urlForDocument *url.URL // urlForDocument is explicitly used for document fetching.
+
+	ctx = context.WithValue(ctx, httpClientContextKey{}, c.HTTPClient) // Provide our HTTP client to parsers for secondary requests
+
+	u, parseErr = url.Parse(urlPath) // Parse the provided URL
+
+	if parseErr != nil { // Failed to parse the provided url
+		return
+	}
+
+	var expandedRedirects []Redirect
+
+	if c.ExpandShorteners { // If we should expand shortened URLs, so our parser is chosen by the destination
+		if u, expandedRedirects, parseErr = c.expand(ctx, u); parseErr != nil {
+			return
+		}
+	}
+
+	if c.NormalizeURLs && c.Normalizer != nil { // If we should normalize our URL before fetching it
+		u = c.Normalizer.Normalize(u)
+	}
+
+	urlForDocument, parseErr = c.rewriters.rewrite(u) // Pass our URL through our rewriters
+
+	if parseErr != nil { // If we had errors from rewriting
+		return
+	}
+
+	request := c.NewRequest(ctx, urlForDocument)
+	response, getErr := c.HTTPClient.Do(request)
+
+	if getErr != nil { // Failed to get a response
+		parseErr = &RequestError{Err: getErr, URL: urlForDocument.String()}
+		return
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 && response.StatusCode != 304 { // Page is not accessible or is not unmodified
+		parseErr = &HTTPStatusError{StatusCode: response.StatusCode, URL: urlForDocument.String()}
+		return
+	}
+
+	contentType := response.Header.Get("content-type")
+	isHTML := strings.HasPrefix(contentType, "text/html")
+	isImage := strings.HasPrefix(contentType, "image/")
+	isVideo := strings.HasPrefix(contentType, "video/")
+
+	if !isHTML && !isImage && !isVideo { // If this is not an HTML page or supported direct link
+		parseErr = &UnsupportedContentError{ContentType: contentType, URL: urlForDocument.String()}
+		return
+	}
+
+	if isImage || isVideo { // If this is an image or video direct link
+		extras := make(map[string]string)
+
+		if isImage { // If this is an image
+			extras["IsImageLink"] = "true"
+		} else if isVideo { // If this is a video
+			extras["IsVideoLink"] = "true"
+		} // Intentionally use else if so we can just continue to extend it in the future
+
+		link = &Link{
+			Description: "",
+			Favicon:     "",
+			Host:        u.Host,
+			Title:       "",
+			URI:         urlPath,
+			Extras:      extras,
+		}
+	} else if isHTML { // If this is an HTML page
+		parser := registeredParser{parser: c.PrimitiveContext, headOnly: c.HeadOnlyPrimitive} // Default to our primitive parser
+		parserURL := u
+
+		if fnForDoc, fnForDocParserExists := c.registry.get(urlForDocument.Host); fnForDocParserExists { // If we have a parser for our document
+			parser = fnForDoc
+			parserURL = urlForDocument
+		} else if fnNoDoc, fnParserExists := c.registry.get(u.Host); fnParserExists { // If we have a parser for our non-parsed / handled URL
+			parser = fnNoDoc
+		}
+
+		if c.ForcedParser != nil { // If we should use a specific parser for all pages
+			parser = registeredParser{parser: c.ForcedParser}
+			parserURL = urlForDocument
+		}
+
+		pageContent, readErr := readPage(response.Body, c.MaxBodySize, parser.headOnly) // Read the body
+
+		if readErr == ErrBodyTooLarge { // If the page exceeded our maximum body size
+			parseErr = readErr
+			return
+		} else if readErr != nil { // If we failed to read page content, such as our context being cancelled
+			parseErr = &RequestError{Err: readErr, URL: urlForDocument.String()}
+			return
+		}
+
+		if pageContent, parseErr = decodePage(pageContent, contentType); parseErr != nil { // If we failed to convert the page to UTF-8
+			parseErr = ErrUnsupportedContent
+			return
+		}
+
+		var doc *goquery.Document
+		doc, parseErr = goquery.NewDocumentFromReader(bytes.NewReader(pageContent))
+
+		if parseErr != nil { // If we failed to create a new document
+			parseErr = ErrUnsupportedContent
+			return
+		}
+
+		doc.Url = response.Request.URL // Set to our final URL after any redirects, so parsers may resolve relative URLs against it
+
+		link, parseErr = parser.parser(ctx, doc, parserURL, urlPath) // Pass along to our parser
+
+		if c.FetchOEmbed && link != nil && parseErr == nil { // If we should enrich our link with oEmbed
+			if endpoint := c.oEmbedEndpoint(doc, u); endpoint != "" {
+				if embed, embedErr := c.fetchOEmbed(ctx, endpoint); embedErr == nil { // oEmbed is optional, so ignore any failure
+					mergeOEmbed(link, embed)
+				}
+			}
+		}
+	}
+
+	if link != nil { // If we have a link, record where the page was fetched from
+		link.FinalURL = response.Request.URL.String()
+		link.Redirects = append(expandedRedirects, redirectChain(response)...)
+	}
+
+	return
+}
+
+// HasOverridden will check if our internal parsers have been overridden
+func (c *Client) HasOverridden(host string) bool {
+	return c.registry.hasOverridden(host)
+}
+
+// NewRequest will create a new page request for the provided URL, with our defined language and user agent
+func (c *Client) NewRequest(ctx context.Context, u *url.URL) *http.Request {
+	var requestHeaders = make(http.Header)
+	requestHeaders.Set("Accept-Language", c.RequestLanguage) // Prefer English
+	requestHeaders.Set("User-Agent", c.UserAgent)
+
+	request := &http.Request{
+		Header: requestHeaders,
+		Method: "GET",
+		URL:    u,
+	}
+
+	return request.WithContext(ctx)
+}
+
+// Normalize will normalize the provided URL using our Normalizer, such as stripping tracking parameters and sorting the query
+func (c *Client) Normalize(rawURL string) (string, error) {
+	normalizer := c.Normalizer
+
+	if normalizer == nil { // No rules set, so use our defaults
+		normalizer = NewNormalizer()
+	}
+
+	return normalizer.NormalizeString(rawURL)
+}
+
+// Register will attempt to register the provided parser for a specific hostname
+// Hostname can be an exact match, such as "google.com", a wildcard such as "*.google.com" or regex such as "^(www\.)?google\.(ca|com)$".
+// Exact matches take precedence over wildcards, which take precedence over regex. The longest wildcard or regex match is used.
+// Options, such as HeadOnly, may be provided to change how pages handled by the parser are fetched.
+// Attempting to register when a LinkParser is already associated will return an error.
+func (c *Client) Register(hostName string, parser LinkParser, options ...ParserOption) error {
+	return c.RegisterContext(hostName, WithContext(parser), options...)
+}
+
+// RegisterContext will attempt to register the provided context-aware parser for a specific hostname
+// Attempting to register when a parser is already associated will return an error.
+func (c *Client) RegisterContext(hostName string, parser ContextLinkParser, options ...ParserOption) error {
+	return c.registry.register(hostName, newRegisteredParser(parser, options), false)
+}
+
+// RegisterRewriter will attempt to register the provided URLRewriter with the provided name
+// Rewriters are applied in order of registration, each receiving the URL from the previous rewriter, prior to fetching the document.
+// Our internal rewriters are registered as "reddit.com", "youtu.be" and "youtube.com", and do not apply once the internal parser of the same hostname is overridden.
+// Attempting to register when a URLRewriter is already registered with this name will return an error.
+func (c *Client) RegisterRewriter(name string, rewriter URLRewriter) error {
+	return c.rewriters.register(name, rewriter, false)
+}
+
+// SetHTTPClient will set the HTTP client used for all requests made by this Client, including secondary requests made by parsers
+// This should be done prior to getting any links with this Client.
+func (c *Client) SetHTTPClient(client *http.Client) error {
+	if client == nil {
+		return errors.New("HTTP client must not be nil")
+	}
+
+	c.HTTPClient = client
+	return nil
+}
+
+// SetRequestLanguage will set the Accept-Language header for page requests
+// This does not necessarily mean the page supports the language or will return with that language
+func (c *Client) SetRequestLanguage(lang string) error {
+	if lang == "" { // If the language is empty
+		return errors.New("language must not be empty")
+	}
+
+	c.RequestLanguage = lang
+	return nil
+}
+
+// SetUserAgent will set the User-Agent header for page requests
+func (c *Client) SetUserAgent(agent string) error {
+	if agent == "" {
+		return errors.New("user agent must not be empty")
+	}
+
+	c.UserAgent = agent
+	return nil
+}
+
+// SetTransport will set the RoundTripper used for all requests made by this Client, such as a proxy, connection pool or test transport
+// The existing HTTP client is copied rather than modified, so a client provided with SetHTTPClient is not changed.
+// This should be done prior to getting any links with this Client.
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	httpClient := *c.HTTPClient // Copy our existing client to retain its timeout, cookie jar and redirect policy
+	httpClient.Transport = transport
+	c.HTTPClient = &httpClient
+}
+
+// Unregister will unregister a LinkParser with the specified hostname
+func (c *Client) Unregister(hostName string) {
+	c.registry.unregister(hostName)
+}
+
+// UnregisterRewriter will unregister the URLRewriter with the provided name
+func (c *Client) UnregisterRewriter(name string) {
+	c.rewriters.unregister(name)
+}
@@ -0,0 +1,101 @@
+// Command sauron-server serves Sauron's link information over HTTP, so link unfurling may be deployed as a service
+// See the server package for the endpoints served.
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/TryStreambits/sauron"
+	"github.com/TryStreambits/sauron/server"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "Address to listen on")
+	apiKeys := flag.String("api-keys", os.Getenv("SAURON_API_KEYS"), "Comma-separated API keys required for /v1 endpoints. Defaults to $SAURON_API_KEYS. If empty, no authentication is required")
+	allowInternal := flag.Bool("allow-internal", false, "Allow fetching internal addresses, such as localhost and private networks")
+	batchTimeout := flag.Duration("batch-timeout", 60*time.Second, "Maximum time for a batch request")
+	maxBatch := flag.Int("max-batch", 50, "Maximum number of URLs in a batch request")
+	maxConcurrency := flag.Int("max-concurrency", 16, "Maximum number of links fetched at once across all requests")
+	maxPerHost := flag.Int("max-per-host", 2, "Maximum number of links fetched at once from a single host within a batch request")
+	shutdownDelay := flag.Duration("shutdown-delay", 5*time.Second, "Time to keep serving after /readyz starts failing when shutting down, so load balancers stop sending traffic first")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 15*time.Second, "Maximum time to wait for in-flight requests when shutting down")
+	timeout := flag.Duration("timeout", 15*time.Second, "Maximum time for fetching a single link")
+	userAgent := flag.String("user-agent", "", "User agent to fetch pages with. Defaults to Sauron's user agent")
+	flag.Parse()
+
+	client := sauron.NewClient()
+	client.HTTPClient.Timeout = *timeout
+
+	if *userAgent != "" {
+		client.SetUserAgent(*userAgent)
+	}
+
+	if !*allowInternal { // Protect against server-side request forgery, as we fetch arbitrary URLs on behalf of callers
+		if dialerErr := client.EnableSafeDialer(); dialerErr != nil {
+			log.Fatalf("Failed to enable safe dialer: %v", dialerErr)
+		}
+	}
+
+	srv := server.NewHandler(client, server.Config{
+		APIKeys:        splitKeys(*apiKeys),
+		BatchTimeout:   *batchTimeout,
+		LinkTimeout:    *timeout,
+		MaxBatch:       *maxBatch,
+		MaxConcurrency: *maxConcurrency,
+		MaxPerHost:     *maxPerHost,
+	})
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      *batchTimeout + 10*time.Second, // Allow our slowest request to complete and be written
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	shutdownComplete := make(chan struct{})
+
+	go func() { // Shut down gracefully when interrupted
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		srv.SetReady(false)        // Stop receiving new traffic from load balancers
+		time.Sleep(*shutdownDelay) // Give load balancers time to see /readyz failing before we stop accepting connections
+
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+
+		if shutdownErr := httpServer.Shutdown(ctx); shutdownErr != nil {
+			log.Printf("Failed to shut down gracefully: %v", shutdownErr)
+		}
+
+		close(shutdownComplete)
+	}()
+
+	log.Printf("Listening on %s", *addr)
+
+	if listenErr := httpServer.ListenAndServe(); listenErr != http.ErrServerClosed {
+		log.Fatalf("Failed to serve: %v", listenErr)
+	}
+
+	<-shutdownComplete
+}
+
+// splitKeys will split the comma-separated API keys, ignoring empty keys
+func splitKeys(keys string) (split []string) {
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			split = append(split, key)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,146 @@
+// Command sauron prints Sauron's link information for the provided URLs, which is useful for debugging how a page is parsed
+//
+// URLs are read from the arguments, or from standard input (one per line) if none are provided or the argument is "-".
+//
+// Usage:
+//
+//	sauron [flags] [url ...]
+//	echo https://example.com | sauron -format markdown
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/TryStreambits/sauron"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// result is the link information or error of a single URL
+type result struct {
+	Error string       `json:"error,omitempty"`
+	Link  *sauron.Link `json:"link,omitempty"`
+	URL   string       `json:"url"`
+}
+
+func main() {
+	concurrency := flag.Int("concurrency", 4, "Maximum number of URLs fetched at once")
+	format := flag.String("format", "json", "Output format: json, ndjson, table or markdown")
+	language := flag.String("language", "", "Accept-Language to request pages with. Defaults to Sauron's language")
+	parserName := flag.String("parser", "", "Parser to force for all pages: primitive, reddit, twitch or youtube. Defaults to choosing by host")
+	timeout := flag.Duration("timeout", 15*time.Second, "Maximum time for fetching each URL")
+	userAgent := flag.String("user-agent", "", "User agent to request pages with. Defaults to Sauron's user agent")
+	flag.Parse()
+
+	writer, formatExists := writers[*format]
+
+	if !formatExists {
+		fatalf("Unknown format %q", *format)
+	}
+
+	client := sauron.NewClient()
+	client.HTTPClient.Timeout = *timeout
+
+	if *language != "" {
+		client.SetRequestLanguage(*language)
+	}
+
+	if *userAgent != "" {
+		client.SetUserAgent(*userAgent)
+	}
+
+	if *parserName != "" { // Force a specific parser
+		parsers := map[string]sauron.ContextLinkParser{
+			"primitive": client.PrimitiveContext,
+			"reddit":    client.RedditContext,
+			"twitch":    client.TwitchContext,
+			"youtube":   client.YoutubeContext,
+		}
+
+		parser, parserExists := parsers[strings.ToLower(*parserName)]
+
+		if !parserExists {
+			fatalf("Unknown parser %q", *parserName)
+		}
+
+		client.ForcedParser = parser
+	}
+
+	urls, readErr := readURLs(flag.Args(), os.Stdin)
+
+	if readErr != nil {
+		fatalf("Failed to read URLs: %v", readErr)
+	}
+
+	if len(urls) == 0 {
+		fatalf("No URLs provided")
+	}
+
+	batchResults := client.GetLinks(context.Background(), urls, &sauron.BatchOptions{
+		Concurrency: *concurrency,
+		Fetch: func(ctx context.Context, urlPath string) (*sauron.Link, error) { // Apply our timeout to each URL rather than the whole batch
+			ctx, cancel := context.WithTimeout(ctx, *timeout)
+			defer cancel()
+			return client.GetLinkContext(ctx, urlPath)
+		},
+	})
+
+	results := make([]result, len(batchResults))
+	failed := false
+
+	for index, batchResult := range batchResults {
+		results[index] = result{Link: batchResult.Link, URL: batchResult.URL}
+
+		if batchResult.Err != nil {
+			results[index].Error = batchResult.Err.Error()
+			failed = true
+		}
+	}
+
+	if writeErr := writer(os.Stdout, results); writeErr != nil {
+		fatalf("Failed to write results: %v", writeErr)
+	}
+
+	if failed { // Indicate at least one URL failed, so scripts may check our exit status
+		os.Exit(1)
+	}
+}
+
+// fatalf will print the provided message to standard error and exit
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(2)
+}
+
+// readURLs will get the URLs from the provided arguments, reading from stdin if there are none or an argument is "-"
+// Blank lines and lines beginning with # are ignored.
+func readURLs(args []string, stdin io.Reader) (urls []string, readErr error) {
+	if len(args) == 0 { // Read everything from stdin
+		args = []string{"-"}
+	}
+
+	for _, arg := range args {
+		if arg != "-" {
+			urls = append(urls, arg)
+			continue
+		}
+
+		scanner := bufio.NewScanner(stdin)
+
+		for scanner.Scan() { // For each line
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				urls = append(urls, line)
+			}
+		}
+
+		if readErr = scanner.Err(); readErr != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// This file contains our output formats
+
+// maxTableCellLength is the maximum length of a table cell, so long descriptions do not make rows unreadable
+const maxTableCellLength = 60
+
+// writers is our output formats by name
+var writers = map[string]func(io.Writer, []result) error{
+	"json":     writeJSON,
+	"markdown": writeMarkdown,
+	"ndjson":   writeNDJSON,
+	"table":    writeTable,
+}
+
+// escapeMarkdown will escape the provided value for use in a Markdown table cell
+func escapeMarkdown(value string) string {
+	value = strings.Replace(value, "|", `\|`, -1)
+	return strings.Join(strings.Fields(value), " ") // Collapse newlines, which would break the table
+}
+
+// fields will get the fields of the provided result to display, in order
+func fields(res result) (names, values []string) {
+	if res.Error != "" {
+		return []string{"Error"}, []string{res.Error}
+	}
+
+	link := res.Link
+	names = []string{"Title", "Description", "Host", "Image", "Favicon", "Final URL", "Canonical URL"}
+	values = []string{link.Title, link.Description, link.Host, link.Image, link.Favicon, link.FinalURL, link.CanonicalURL}
+
+	if link.Entity != nil { // Include our structured data type, which is often why a page renders differently
+		names = append(names, "Entity")
+		values = append(values, link.Entity.Type)
+	}
+
+	extras := make([]string, 0, len(link.Extras))
+
+	for extrasType := range link.Extras {
+		extras = append(extras, extrasType)
+	}
+
+	sort.Strings(extras)
+
+	for _, extrasType := range extras { // Include our extras in a stable order
+		names = append(names, "Extras."+extrasType)
+		values = append(values, link.Extras[extrasType])
+	}
+
+	return
+}
+
+// truncate will shorten the provided value to the maximum length, indicating it was shortened
+func truncate(value string, maxLength int) string {
+	value = strings.Join(strings.Fields(value), " ")
+	runes := []rune(value)
+
+	if len(runes) <= maxLength {
+		return value
+	}
+
+	return string(runes[:maxLength-1]) + "…"
+}
+
+// writeJSON will write the results as an indented JSON array
+func writeJSON(w io.Writer, results []result) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// writeMarkdown will write each result as a Markdown section with a table of its fields
+func writeMarkdown(w io.Writer, results []result) error {
+	for index, res := range results {
+		if index > 0 {
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintf(w, "## %s\n\n| Field | Value |\n| --- | --- |\n", escapeMarkdown(res.URL))
+		names, values := fields(res)
+
+		for fieldIndex, name := range names {
+			if _, writeErr := fmt.Fprintf(w, "| %s | %s |\n", name, escapeMarkdown(values[fieldIndex])); writeErr != nil {
+				return writeErr
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeNDJSON will write each result as a JSON object on its own line
+func writeNDJSON(w io.Writer, results []result) error {
+	encoder := json.NewEncoder(w)
+
+	for _, res := range results {
+		if encodeErr := encoder.Encode(res); encodeErr != nil {
+			return encodeErr
+		}
+	}
+
+	return nil
+}
+
+// writeTable will write the results as an aligned table with a row per URL
+func writeTable(w io.Writer, results []result) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "URL\tTITLE\tDESCRIPTION\tIMAGE\tERROR")
+
+	for _, res := range results {
+		var title, description, image string
+
+		if res.Link != nil {
+			title, description, image = res.Link.Title, res.Link.Description, res.Link.Image
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", res.URL, truncate(title, maxTableCellLength), truncate(description, maxTableCellLength), image, truncate(res.Error, maxTableCellLength))
+	}
+
+	return table.Flush()
+}
@@ -0,0 +1,100 @@
+package sauron
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// This file contains our coalescing of concurrent requests for the same URL, so popular links are only fetched once
+
+// inflightCall is a fetch shared by all concurrent requests for the same URL
+type inflightCall struct {
+	cancel  context.CancelFunc // cancel will cancel the fetch, once every waiter has given up
+	done    chan struct{}      // done is closed once the fetch has completed
+	err     error
+	link    *Link
+	waiters int
+}
+
+// inflightGroup is our in-flight fetches by key
+type inflightGroup struct {
+	calls map[string]*inflightCall
+	mutex sync.Mutex
+}
+
+// valuesContext is a context with the values of its parent, but not its deadline or cancellation
+// This allows a shared fetch to outlive the caller which started it, while parsers still receive request-scoped values such as for tracing or authentication.
+type valuesContext struct {
+	context.Context
+}
+
+// Deadline will indicate our context has no deadline
+func (valuesContext) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+// Done will return nil, as our context is never cancelled
+func (valuesContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err will return nil, as our context is never cancelled
+func (valuesContext) Err() error {
+	return nil
+}
+
+// newInflightGroup will create a new, empty inflightGroup
+func newInflightGroup() *inflightGroup {
+	return &inflightGroup{calls: make(map[string]*inflightCall)}
+}
+
+// do will call fetch for the key, or wait for the fetch already in flight for the key
+// The fetch uses its own context with the values of the caller that started it, so it is not cancelled when that caller gives up. It is only cancelled once every waiter has given up.
+// Each caller gets its own copy of the link, so callers may modify their link without affecting others.
+func (group *inflightGroup) do(ctx context.Context, key string, fetch func(context.Context) (*Link, error)) (*Link, error) {
+	group.mutex.Lock()
+	call, exists := group.calls[key]
+
+	if !exists { // First request for this key, so start our fetch
+		fetchCtx, cancel := context.WithCancel(valuesContext{ctx})
+		call = &inflightCall{cancel: cancel, done: make(chan struct{})}
+		group.calls[key] = call
+
+		go func() {
+			call.link, call.err = fetch(fetchCtx)
+			cancel()
+
+			group.mutex.Lock()
+
+			if group.calls[key] == call { // Not already removed by every waiter giving up
+				delete(group.calls, key)
+			}
+
+			group.mutex.Unlock()
+			close(call.done)
+		}()
+	}
+
+	call.waiters++
+	group.mutex.Unlock()
+
+	select {
+	case <-call.done: // Fetch completed
+		return call.link.Copy(), call.err
+	case <-ctx.Done(): // Gave up waiting
+		group.mutex.Lock()
+		call.waiters--
+
+		if call.waiters == 0 { // No one is waiting for this fetch, so cancel it and ensure new requests start a new fetch
+			call.cancel()
+
+			if group.calls[key] == call {
+				delete(group.calls, key)
+			}
+		}
+
+		group.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,265 @@
+package sauron
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// This file contains our on-disk Cache
+
+// diskCacheSentinels is our sentinel errors which may be returned by GetLink as is, by the name they are stored on disk with
+var diskCacheSentinels = map[string]error{
+	"bodyTooLarge":       ErrBodyTooLarge,
+	"tooManyRedirects":   ErrTooManyRedirects,
+	"unsupportedContent": ErrUnsupportedContent,
+}
+
+// DiskCache is a Cache storing each entry as a JSON file in a directory, so entries persist across restarts
+// Cached errors are restored as the same type, so they match the same errors with errors.Is and errors.As, such as ErrTimeout or ErrBlockedAddress.
+// Errors which can not be restored, such as from a custom parser, are not stored.
+// Entries past their StaleUntil are only removed when read or by Sweep, so Sweep should be called periodically to limit the size of the directory.
+type DiskCache struct {
+	// Directory is the directory entries are stored in
+	Directory string
+}
+
+// diskCacheEntry is how a CacheEntry is stored on disk
+type diskCacheEntry struct {
+	Error      *diskCacheError `json:"error,omitempty"`
+	Expires    time.Time       `json:"expires"`
+	Key        string          `json:"key"`
+	Link       *Link           `json:"link,omitempty"`
+	StaleUntil time.Time       `json:"staleUntil"`
+}
+
+// diskCacheError is how an error is stored on disk, as its kind and the fields required to restore it
+type diskCacheError struct {
+	Address     string `json:"address,omitempty"` // Address is the refused address, if the cause of a RequestError was a BlockedAddressError
+	Cause       string `json:"cause,omitempty"`   // Cause is the message of the underlying error of a RequestError
+	ContentType string `json:"contentType,omitempty"`
+	IP          string `json:"ip,omitempty"`
+	Kind        string `json:"kind"` // Kind is request, sentinel, status or unsupportedContent
+	Sentinel    string `json:"sentinel,omitempty"`
+	StatusCode  int    `json:"statusCode,omitempty"`
+	Timeout     bool   `json:"timeout,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+// restoredCause is the underlying error of a RequestError restored from disk
+// This retains the message of the original error, whether it was a timeout and any BlockedAddressError.
+type restoredCause struct {
+	blocked *BlockedAddressError
+	message string
+	timeout bool
+}
+
+// Error will return the message of the original error
+func (cause *restoredCause) Error() string {
+	return cause.message
+}
+
+// Timeout will indicate if the original error was a timeout
+func (cause *restoredCause) Timeout() bool {
+	return cause.timeout
+}
+
+// Unwrap will return the BlockedAddressError of the original error, if any
+func (cause *restoredCause) Unwrap() error {
+	if cause.blocked == nil {
+		return nil
+	}
+
+	return cause.blocked
+}
+
+// newDiskCacheError will convert the provided error for storing on disk
+// False is returned if the error can not be restored as the same type, so it should not be stored.
+func newDiskCacheError(err error) (stored *diskCacheError, ok bool) {
+	switch typedErr := err.(type) {
+	case *HTTPStatusError:
+		return &diskCacheError{Kind: "status", StatusCode: typedErr.StatusCode, URL: typedErr.URL}, true
+	case *RequestError:
+		stored = &diskCacheError{Cause: typedErr.Err.Error(), Kind: "request", Timeout: typedErr.Timeout(), URL: typedErr.URL}
+
+		var blockedErr *BlockedAddressError
+
+		if errors.As(typedErr.Err, &blockedErr) { // Refused by our SafeDialer
+			stored.Address = blockedErr.Address
+			stored.IP = blockedErr.IP.String()
+		}
+
+		return stored, true
+	case *UnsupportedContentError:
+		return &diskCacheError{ContentType: typedErr.ContentType, Kind: "unsupportedContent", URL: typedErr.URL}, true
+	}
+
+	for name, sentinel := range diskCacheSentinels {
+		if err == sentinel {
+			return &diskCacheError{Kind: "sentinel", Sentinel: name}, true
+		}
+	}
+
+	return nil, false
+}
+
+// restore will restore our stored error as its original type
+// False is returned if the error is of an unknown kind, such as from a newer version of Sauron.
+func (stored *diskCacheError) restore() (error, bool) {
+	switch stored.Kind {
+	case "request":
+		cause := &restoredCause{message: stored.Cause, timeout: stored.Timeout}
+
+		if stored.Address != "" { // Refused by our SafeDialer
+			cause.blocked = &BlockedAddressError{Address: stored.Address, IP: net.ParseIP(stored.IP)}
+		}
+
+		return &RequestError{Err: cause, URL: stored.URL}, true
+	case "sentinel":
+		sentinel, exists := diskCacheSentinels[stored.Sentinel]
+		return sentinel, exists
+	case "status":
+		return &HTTPStatusError{StatusCode: stored.StatusCode, URL: stored.URL}, true
+	case "unsupportedContent":
+		return &UnsupportedContentError{ContentType: stored.ContentType, URL: stored.URL}, true
+	}
+
+	return nil, false
+}
+
+// NewDiskCache will create a new DiskCache in the provided directory, creating it if it does not exist
+func NewDiskCache(directory string) (*DiskCache, error) {
+	if mkdirErr := os.MkdirAll(directory, 0755); mkdirErr != nil {
+		return nil, mkdirErr
+	}
+
+	return &DiskCache{Directory: directory}, nil
+}
+
+// Delete will remove the entry for the key, if any
+func (cache *DiskCache) Delete(key string) {
+	os.Remove(cache.path(key))
+}
+
+// Get will get the entry for the key, if any
+// Entries which are past their StaleUntil, or can not be read, are removed rather than returned.
+func (cache *DiskCache) Get(key string) (*CacheEntry, bool) {
+	content, readErr := ioutil.ReadFile(cache.path(key))
+
+	if readErr != nil { // Not cached
+		return nil, false
+	}
+
+	var stored diskCacheEntry
+
+	if jsonErr := json.Unmarshal(content, &stored); jsonErr != nil { // Corrupt
+		cache.Delete(key)
+		return nil, false
+	}
+
+	if stored.Key != key { // Entry for a different key with the same hash, which we leave in place
+		return nil, false
+	}
+
+	if !time.Now().Before(stored.StaleUntil) { // Can no longer be used
+		cache.Delete(key)
+		return nil, false
+	}
+
+	entry := &CacheEntry{Expires: stored.Expires, Link: stored.Link, StaleUntil: stored.StaleUntil}
+
+	if stored.Error != nil { // Cached failure
+		var restored bool
+
+		if entry.Err, restored = stored.Error.restore(); !restored {
+			cache.Delete(key)
+			return nil, false
+		}
+	}
+
+	return entry, true
+}
+
+// Set will store the entry for the key, replacing any existing entry
+// The entry is written to a temporary file and renamed, so concurrent readers never see a partially written entry.
+// If the entry is an error which can not be restored, any existing entry is removed instead.
+func (cache *DiskCache) Set(key string, entry *CacheEntry) {
+	stored := diskCacheEntry{Expires: entry.Expires, Key: key, Link: entry.Link, StaleUntil: entry.StaleUntil}
+
+	if entry.Err != nil {
+		var storable bool
+
+		if stored.Error, storable = newDiskCacheError(entry.Err); !storable { // Would not match the same errors once restored
+			cache.Delete(key)
+			return
+		}
+	}
+
+	content, jsonErr := json.Marshal(stored)
+
+	if jsonErr != nil { // Can not be stored, such as a link with unsupported Extras
+		return
+	}
+
+	temporary, createErr := ioutil.TempFile(cache.Directory, ".entry-*")
+
+	if createErr != nil {
+		return
+	}
+
+	_, writeErr := temporary.Write(content)
+	closeErr := temporary.Close()
+
+	if writeErr != nil || closeErr != nil || os.Rename(temporary.Name(), cache.path(key)) != nil { // Failed to write or move into place
+		os.Remove(temporary.Name())
+	}
+}
+
+// Sweep will remove every entry which is past its StaleUntil or can not be read, returning the number of entries removed
+// Entries being written are not removed, as they are not yet in place.
+func (cache *DiskCache) Sweep() (removed int, sweepErr error) {
+	files, sweepErr := ioutil.ReadDir(cache.Directory)
+
+	if sweepErr != nil { // Failed to list our entries
+		return
+	}
+
+	now := time.Now()
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" { // Not an entry, such as a temporary file being written
+			continue
+		}
+
+		path := filepath.Join(cache.Directory, file.Name())
+		content, readErr := ioutil.ReadFile(path)
+
+		if readErr != nil { // Removed since we listed our entries
+			continue
+		}
+
+		var stored diskCacheEntry
+
+		if json.Unmarshal(content, &stored) == nil && now.Before(stored.StaleUntil) { // Still usable
+			continue
+		}
+
+		if os.Remove(path) == nil {
+			removed++
+		}
+	}
+
+	return
+}
+
+// path will get the path of the file for the key
+func (cache *DiskCache) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(cache.Directory, hex.EncodeToString(hash[:])+".json")
+}
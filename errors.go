@@ -0,0 +1,105 @@
+package sauron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// This file contains our error values and types, which may be inspected with errors.Is and errors.As
+
+var (
+	// ErrBodyTooLarge is returned when a page exceeds the maximum body size of the Client
+	ErrBodyTooLarge = errors.New("Page content exceeds maximum body size")
+
+	// ErrHostAlreadyRegistered is returned when a host already has a registered parser
+	ErrHostAlreadyRegistered = errors.New(HostAlreadyRegistered)
+
+	// ErrNoResponse is matched by any error where we failed to get a response from a page
+	ErrNoResponse = errors.New(NoResponse)
+
+	// ErrPageNotAccessible is matched by any HTTPStatusError
+	ErrPageNotAccessible = errors.New(PageNotAccessible)
+
+	// ErrRewriterAlreadyRegistered is returned when a URL rewriter is already registered with the name
+	ErrRewriterAlreadyRegistered = errors.New(RewriterAlreadyRegistered)
+
+	// ErrTimeout is matched by any error where the page or a secondary request timed out, including exceeding a context deadline
+	ErrTimeout = errors.New("Timed out waiting for page")
+
+	// ErrTooManyRedirects is returned when expanding a shortened URL exceeds the maximum number of redirects of the Client
+	ErrTooManyRedirects = errors.New("Exceeded maximum redirects while expanding URL")
+
+	// ErrUnsupportedContent is matched by any error where the page content is not HTML or a supported direct link
+	ErrUnsupportedContent = errors.New(PageContentNotValid)
+)
+
+// HTTPStatusError is an error for when a page returns a status we can not parse, such as 404 or 503
+type HTTPStatusError struct {
+	StatusCode int
+	URL        string
+}
+
+// Error will return our error message, including the status code and URL
+func (statusErr *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s: %s returned %d %s", PageNotAccessible, statusErr.URL, statusErr.StatusCode, http.StatusText(statusErr.StatusCode))
+}
+
+// Is will indicate that our HTTPStatusError matches ErrPageNotAccessible
+func (statusErr *HTTPStatusError) Is(target error) bool {
+	return target == ErrPageNotAccessible
+}
+
+// Temporary will indicate if the request may succeed if retried, such as for 429 Too Many Requests or 503 Service Unavailable
+func (statusErr *HTTPStatusError) Temporary() bool {
+	return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+}
+
+// RequestError is an error for when we fail to get a response from a page, or fail to read it
+// The underlying network or context error is available via errors.Unwrap.
+type RequestError struct {
+	Err error
+	URL string
+}
+
+// Error will return our error message, including the underlying error
+func (requestErr *RequestError) Error() string {
+	return fmt.Sprintf("%s: %v", NoResponse, requestErr.Err)
+}
+
+// Is will indicate that our RequestError matches ErrNoResponse, as well as ErrTimeout if the underlying error was a timeout
+func (requestErr *RequestError) Is(target error) bool {
+	return target == ErrNoResponse || (target == ErrTimeout && requestErr.Timeout())
+}
+
+// Timeout will indicate if the underlying error was a timeout, such as our HTTP client timeout or a context deadline being exceeded
+func (requestErr *RequestError) Timeout() bool {
+	if errors.Is(requestErr.Err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var timeoutErr interface{ Timeout() bool }
+	return errors.As(requestErr.Err, &timeoutErr) && timeoutErr.Timeout()
+}
+
+// Unwrap will return the underlying error
+func (requestErr *RequestError) Unwrap() error {
+	return requestErr.Err
+}
+
+// UnsupportedContentError is an error for when a page returns content which is not HTML or a supported direct link
+type UnsupportedContentError struct {
+	ContentType string
+	URL         string
+}
+
+// Error will return our error message, including the content type and URL
+func (contentErr *UnsupportedContentError) Error() string {
+	return fmt.Sprintf("%s: %s returned content type %q", PageContentNotValid, contentErr.URL, contentErr.ContentType)
+}
+
+// Is will indicate that our UnsupportedContentError matches ErrUnsupportedContent
+func (contentErr *UnsupportedContentError) Is(target error) bool {
+	return target == ErrUnsupportedContent
+}
@@ -3,6 +3,8 @@ module github.com/TryStreambits/sauron
 go 1.15
 
 require (
-	github.com/JoshStrobl/trunk v0.0.0-20200218090856-fe3310723adb // indirect
+	github.com/JoshStrobl/trunk v0.0.0-20200218090856-fe3310723adb
 	github.com/PuerkitoBio/goquery v1.5.1
+	golang.org/x/net v0.0.0-20200202094626-16171245cfb2
+	golang.org/x/text v0.3.0
 )
@@ -0,0 +1,159 @@
+package sauron
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/PuerkitoBio/goquery"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file contains our favicon and web app manifest icon discovery
+
+// maxManifestSize is the maximum number of bytes read from a web app manifest
+const maxManifestSize = 1 << 20 // 1 MiB
+
+// scalableIconSize is the size used for ranking icons with sizes="any", such as SVG icons, so they are preferred over any fixed size
+const scalableIconSize = 1 << 16
+
+// iconRels are the link relations which declare an icon
+var iconRels = map[string]bool{
+	"apple-touch-icon":             true,
+	"apple-touch-icon-precomposed": true,
+	"icon":                         true,
+	"mask-icon":                    true,
+}
+
+// Icon is an icon of a page, such as a favicon, apple-touch-icon or web app manifest icon
+type Icon struct {
+	// Purpose is the purpose of a web app manifest icon, such as any, maskable or monochrome
+	Purpose string
+
+	// Rel is where the icon was discovered, such as icon, apple-touch-icon, mask-icon, manifest or favicon.ico for our fallback
+	Rel string
+
+	// Sizes is the sizes the icon is declared with, such as 32x32, 16x16 32x32 or any
+	Sizes string
+
+	// Type is the declared MIME type of the icon, such as image/png
+	Type string
+
+	URL string
+}
+
+// manifest is the parts of a web app manifest we use
+type manifest struct {
+	Icons []struct {
+		Purpose string `json:"purpose"`
+		Sizes   string `json:"sizes"`
+		Src     string `json:"src"`
+		Type    string `json:"type"`
+	} `json:"icons"`
+}
+
+// Size will get the largest dimension of the icon's declared sizes
+// Scalable icons with sizes="any" are given a size larger than any fixed size. 0 is returned if the icon has no valid size.
+func (icon Icon) Size() (largest int) {
+	for _, size := range strings.Fields(strings.ToLower(icon.Sizes)) { // For each size, such as 32x32
+		if size == "any" { // Scalable
+			return scalableIconSize
+		}
+
+		for _, dimension := range strings.SplitN(size, "x", 2) { // Use the larger of the width and height
+			if value, convErr := strconv.Atoi(dimension); convErr == nil && value > largest {
+				largest = value
+			}
+		}
+	}
+
+	return
+}
+
+// rankTier will get the tier of the icon for ranking, where lower tiers are preferred
+// Icons meant to be recolored or masked are less suitable for display as-is, and our /favicon.ico fallback is only a guess.
+func (icon Icon) rankTier() int {
+	if icon.Rel == "favicon.ico" { // Our fallback
+		return 2
+	}
+
+	if icon.Rel == "mask-icon" { // Monochrome SVG for Safari pinned tabs
+		return 1
+	}
+
+	if icon.Purpose != "" && !strings.Contains(" "+icon.Purpose+" ", " any ") { // Manifest icon only intended for maskable or monochrome use
+		return 1
+	}
+
+	return 0
+}
+
+// parseIcons will get the icons declared by the link elements of the document, resolved against the base URL
+func parseIcons(doc *goquery.Document, baseURL *url.URL) (icons []Icon, manifestURL string) {
+	doc.Find("link[rel][href]").Each(func(index int, selection *goquery.Selection) { // For each link
+		href := ResolveURL(baseURL, selection.AttrOr("href", ""))
+
+		if href == "" { // No valid URL
+			return
+		}
+
+		for _, rel := range strings.Fields(strings.ToLower(selection.AttrOr("rel", ""))) { // For each relation, such as shortcut and icon
+			if rel == "manifest" && manifestURL == "" { // First web app manifest
+				manifestURL = href
+			} else if iconRels[rel] { // Is an icon
+				icons = append(icons, Icon{
+					Rel:   rel,
+					Sizes: strings.TrimSpace(selection.AttrOr("sizes", "")),
+					Type:  strings.TrimSpace(selection.AttrOr("type", "")),
+					URL:   href,
+				})
+
+				return
+			}
+		}
+	})
+
+	return
+}
+
+// rankIcons will sort the icons from most to least preferred
+// Icons are ranked by their tier, then by size with the largest first. Icons of equal rank keep their document order.
+func rankIcons(icons []Icon) {
+	sort.SliceStable(icons, func(i, j int) bool {
+		if icons[i].rankTier() != icons[j].rankTier() {
+			return icons[i].rankTier() < icons[j].rankTier()
+		}
+
+		return icons[i].Size() > icons[j].Size()
+	})
+}
+
+// fetchManifestIcons will fetch the web app manifest and get its icons, resolved against the manifest URL
+func (c *Client) fetchManifestIcons(ctx context.Context, manifestURL string) (icons []Icon, fetchErr error) {
+	content, finalURL, fetchErr := c.fetchResource(ctx, manifestURL, maxManifestSize)
+
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	var parsed manifest
+
+	if fetchErr = json.Unmarshal(content, &parsed); fetchErr != nil { // Not a valid manifest
+		return
+	}
+
+	for _, manifestIcon := range parsed.Icons { // For each icon in our manifest
+		if src := ResolveURL(finalURL, manifestIcon.Src); src != "" {
+			icons = append(icons, Icon{
+				Purpose: strings.TrimSpace(manifestIcon.Purpose),
+				Rel:     "manifest",
+				Sizes:   strings.TrimSpace(manifestIcon.Sizes),
+				Type:    strings.TrimSpace(manifestIcon.Type),
+				URL:     src,
+			})
+		}
+	}
+
+	return
+}
@@ -1,32 +1,93 @@
 package sauron
 
 import (
+	"context"
 	"github.com/PuerkitoBio/goquery"
 	"net/url"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
 // This files contains our internally supported parsers
 
-// MetaImageNames is an array of meta names commonly associated with site images
-var MetaImageNames []string
+// openGraphExtras is a map of extras to the Open Graph and Twitter Card meta names they are set from, in order of precedence
+var openGraphExtras = map[string][]string{
+	"Audio":               {"og:audio:secure_url", "og:audio:url", "og:audio"},
+	"AudioType":           {"og:audio:type"},
+	"ImageAlt":            {"og:image:alt", "twitter:image:alt"},
+	"ImageHeight":         {"og:image:height"},
+	"ImageWidth":          {"og:image:width"},
+	"Locale":              {"og:locale"},
+	"OpenGraphType":       {"og:type"},
+	"OpenGraphURL":        {"og:url"},
+	"SiteName":            {"og:site_name"},
+	"TwitterCard":         {"twitter:card"},
+	"TwitterCreator":      {"twitter:creator"},
+	"TwitterPlayer":       {"twitter:player"},
+	"TwitterPlayerHeight": {"twitter:player:height"},
+	"TwitterPlayerWidth":  {"twitter:player:width"},
+	"TwitterSite":         {"twitter:site"},
+	"Video":               {"og:video:secure_url", "og:video:url", "og:video"},
+	"VideoHeight":         {"og:video:height"},
+	"VideoType":           {"og:video:type"},
+	"VideoWidth":          {"og:video:width"},
+}
+
+// urlExtras is the extras from openGraphExtras which are URLs, and are resolved against the base URL of the page
+var urlExtras = map[string]bool{
+	"Audio":         true,
+	"OpenGraphURL":  true,
+	"TwitterPlayer": true,
+	"Video":         true,
+}
+
+// Primitive is our primitive parser, using the DefaultClient
+// This parser will get standard page information from the most commonly supported DOM Elements
+func Primitive(doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return DefaultClient.PrimitiveContext(context.Background(), doc, url, fullURL)
+}
 
-func init() {
-	MetaImageNames = []string{"og:image", "twitter:image"}
+// PrimitiveContext is our primitive parser, using the DefaultClient and the provided context for any manifest request
+func PrimitiveContext(ctx context.Context, doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return DefaultClient.PrimitiveContext(ctx, doc, url, fullURL)
 }
 
 // Primitive is our primitive parser
 // This parser will get standard page information from the most commonly supported DOM Elements
-func Primitive(doc *goquery.Document, url *url.URL, fullURL string) (link *Link, parserErr error) {
+func (c *Client) Primitive(doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return c.PrimitiveContext(context.Background(), doc, url, fullURL)
+}
+
+// PrimitiveContext is our primitive parser, using the provided context for fetching the web app manifest if FetchManifestIcons is enabled
+// This parser will get standard page information from the most commonly supported DOM Elements
+func (c *Client) PrimitiveContext(ctx context.Context, doc *goquery.Document, url *url.URL, fullURL string) (link *Link, parserErr error) {
 	link = &Link{
-		Description: "",                              // Create an empty description for now
-		Favicon:     "",                              // Create an empty favicon for now
-		Host:        url.Host,                        // Set to our provided host
-		Title:       doc.Find("head > title").Text(), // Set to standard title
-		URI:         fullURL,                         // Set to provided URL
-		Extras:      make(map[string]string),         // Create an empty map
+		Description: "",                                            // Create an empty description for now
+		Favicon:     "",                                            // Create an empty favicon for now
+		Host:        url.Host,                                      // Set to our provided host
+		Title:       metaContent(doc, "og:title", "twitter:title"), // Prefer the title intended for sharing
+		URI:         fullURL,                                       // Set to provided URL
+		Entity:      ParseJSONLD(doc),                              // Get any JSON-LD entity
+		Extras:      make(map[string]string),                       // Create an empty map
+		Items:       ParseItems(doc),                               // Get any microdata and RDFa items
+	}
+
+	baseURL := BaseURL(doc, url) // Get the URL which relative URLs are resolved against
+
+	if link.Entity == nil { // If the page has no JSON-LD, use its microdata or RDFa
+		link.Entity = entityFromItems(link.Items)
+	}
+
+	if link.Entity != nil { // Resolve our entity image, as the document may not have its final URL set for the structured data to be resolved against
+		link.Entity.Image = ResolveURL(baseURL, link.Entity.Image)
+	}
+
+	if link.Title == "" && link.Entity != nil { // If the page does not provide a title for sharing, use the structured data headline
+		link.Title = link.Entity.Headline
+	}
+
+	if link.Title == "" { // If we still do not have a title
+		link.Title = doc.Find("head > title").Text() // Set to standard title
 	}
 
 	if link.Title != "" { // If we got a title
@@ -37,55 +98,41 @@ func Primitive(doc *goquery.Document, url *url.URL, fullURL string) (link *Link,
 
 	// #region Description Fetching
 
-	var description string // Set description to an empty string
+	link.Description = metaContent(doc, "og:description", "twitter:description", "description") // Prefer the description intended for sharing
 
-	if metaDescription, hasMetaDescription := doc.Find(`meta[name="description"]`).Attr("content"); hasMetaDescription { // If we found a standard meta description
-		description = metaDescription
-	} else { // If we did not find a standard meta description
-		description = doc.Find(`meta[name="og:description"]`).AttrOr("content", "") // Attempt to get og:description and revert to empty string
+	if link.Description == "" && link.Entity != nil { // If the page has no description meta, use the structured data description
+		link.Description = link.Entity.Description
 	}
 
-	link.Description = description // Update our description
-
 	// #endregion
 
 	// #region Favicon Fetching
 
-	var favicon string
-	var largestSize int
-
-	doc.Find(`link[rel="icon"]`).Each(func(index int, selection *goquery.Selection) { // For each link we found
-		faviconAttr := selection.AttrOr("href", "")
+	var manifestURL string
+	link.Icons, manifestURL = parseIcons(doc, baseURL) // Get the icons declared by the page
 
-		if faviconAttr != "" { // Provided icon is not a string
-			if !strings.HasPrefix(faviconAttr, "http") { // Is not absolute URL
-				hostPrefix := url.Scheme + "://" + url.Host
+	if manifestURL != "" { // If the page has a web app manifest
+		link.Extras["Manifest"] = manifestURL
 
-				if strings.HasPrefix(faviconAttr, "/") { // At least starts with a slash
-					faviconAttr = hostPrefix + faviconAttr // Just append favicon to hostPrefix
-				} else {
-					faviconAttr = hostPrefix + "/" + faviconAttr // Ensure there is a slash between
-				}
+		if c.FetchManifestIcons { // If we should get the icons from the manifest
+			if manifestIcons, manifestErr := c.fetchManifestIcons(ctx, manifestURL); manifestErr == nil { // Icons are optional, so ignore any failure
+				link.Icons = append(link.Icons, manifestIcons...)
 			}
 		}
+	}
 
-		if sizeAttr, hasSizeAttr := selection.Attr("size"); hasSizeAttr { // If this icon has a size attribute
-			sizeArr := strings.Split(sizeAttr, "x") // Split on x, which is common for sizes (ex. 32x32)
+	if len(link.Icons) == 0 { // If the page does not declare any icons, fall back to /favicon.ico on the page's host
+		pageURL := url
 
-			if len(sizeArr) > 0 { // If we have lengths
-				if iconSize, convErr := strconv.Atoi(sizeArr[0]); convErr == nil { // Convert our string to an int
-					if iconSize > largestSize && faviconAttr != "" { // If this is our largest size yet and has an icon href
-						favicon = faviconAttr  // Update our favicon
-						largestSize = iconSize // Update our largest size
-					}
-				}
-			}
-		} else if !hasSizeAttr && favicon == "" && faviconAttr != "" { // If no size is indicated and we haven't set an icon yet
-			favicon = faviconAttr
+		if doc.Url != nil { // If we have the final URL of the page
+			pageURL = doc.Url
 		}
-	})
 
-	link.Favicon = favicon // Update our favicon
+		link.Icons = append(link.Icons, Icon{Rel: "favicon.ico", URL: ResolveURL(pageURL, "/favicon.ico")})
+	}
+
+	rankIcons(link.Icons)
+	link.Favicon = link.Icons[0].URL // Use our best icon
 
 	// #endregion
 
@@ -93,36 +140,73 @@ func Primitive(doc *goquery.Document, url *url.URL, fullURL string) (link *Link,
 
 	var image string // Set image to an empty string
 
-	for _, metaImageType := range MetaImageNames {
-		if metaImage, hasMetaImage := doc.Find(`meta[name="` + metaImageType + `"]`).Attr("content"); hasMetaImage { // If we found this meta image
-			image = metaImage
-			break
-		}
+	image = metaContent(doc, c.MetaImageNames...) // Get the first meta image we find
+
+	if image == "" && link.Entity != nil { // If we did not find an image from the metadata, use the structured data image
+		image = link.Entity.Image
 	}
 
-	if image == "" { // If we did not find an image from the metadata
+	if image == "" { // If we still do not have an image
 		if firstImage, hasImageOnPage := doc.Find("img").Attr("src"); hasImageOnPage { // If we found an image on the page, so just the first one we find
 			image = firstImage
 		}
 	}
 
-	if image != "" && strings.HasPrefix(image, "/") { // Image is set and is a relative URL
-		image = link.Host + image // Prepend host
-	}
+	link.Image = ResolveURL(baseURL, image) // Resolve the image against our base URL
+
+	// #endregion
 
-	link.Image = image
+	// #region Open Graph and Twitter Card Parsing
 
-	if link.Image != "" && !strings.HasPrefix(link.Image, "http") { // If a link is relative
-		prefixString := url.Scheme + ":"
+	for extrasType, metaNames := range openGraphExtras { // For each of our Open Graph and Twitter Card extras
+		if content := metaContent(doc, metaNames...); content != "" { // If the page provides this metadata
+			if urlExtras[extrasType] { // If this metadata is a URL
+				content = ResolveURL(baseURL, content)
+			}
 
-		if !strings.HasPrefix(link.Image, "//") { // If it doesn't start with //, which is common for Reddit or relative to the scheme being used
-			prefixString += "//" // Append //
+			link.Extras[extrasType] = content
 		}
+	}
+
+	// #endregion
+
+	// #region Canonical URL Fetching
 
-		link.Image = prefixString + link.Image // Prepend
+	if canonical := ResolveURL(baseURL, doc.Find(`link[rel~="canonical"]`).First().AttrOr("href", "")); canonical != "" { // If the page declares a canonical URL
+		link.CanonicalURL = canonical
+	} else { // Fall back to the Open Graph URL, which is already resolved
+		link.CanonicalURL = link.Extras["OpenGraphURL"]
 	}
 
 	// #endregion
 
+	// #region Feed Fetching
+
+	doc.Find(`link[rel~="alternate"][type="application/rss+xml"], link[rel~="alternate"][type="application/atom+xml"]`).EachWithBreak(func(index int, selection *goquery.Selection) bool { // For each feed we found
+		if feed := ResolveURL(baseURL, selection.AttrOr("href", "")); feed != "" { // Use the first feed with a valid URL
+			link.Extras["Feed"] = feed
+			link.Extras["FeedType"] = selection.AttrOr("type", "")
+			return false
+		}
+
+		return true
+	})
+
+	// #endregion
+
 	return
 }
+
+// metaContent will get the content of the first meta element with a property or name matching one of the provided names
+// Names are checked in order of precedence. Both property and name are checked, as sites commonly use either for Open Graph.
+func metaContent(doc *goquery.Document, names ...string) string {
+	for _, name := range names { // For each name, in order of precedence
+		if content, hasContent := doc.Find(`meta[property="` + name + `"], meta[name="` + name + `"]`).Attr("content"); hasContent { // If we found this meta
+			if content = strings.TrimSpace(content); content != "" {
+				return content
+			}
+		}
+	}
+
+	return ""
+}
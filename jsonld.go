@@ -0,0 +1,272 @@
+package sauron
+
+import (
+	"encoding/json"
+	"github.com/PuerkitoBio/goquery"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// This file contains our JSON-LD structured data parsing
+
+// jsonLDURLProperties are schema.org properties whose values are URLs, and are resolved against the base URL of the page
+var jsonLDURLProperties = map[string]bool{
+	"contentUrl":   true,
+	"embedUrl":     true,
+	"image":        true,
+	"logo":         true,
+	"sameAs":       true,
+	"thumbnailUrl": true,
+	"url":          true,
+}
+
+// supportingEntityTypes are schema.org types which describe the site or page rather than its primary content
+// These are only used as our primary entity if the page provides nothing else.
+var supportingEntityTypes = map[string]bool{
+	"AboutPage":               true,
+	"BreadcrumbList":          true,
+	"CollectionPage":          true,
+	"ContactPage":             true,
+	"EducationalOrganization": true,
+	"FAQPage":                 true,
+	"ImageObject":             true,
+	"ItemPage":                true,
+	"MedicalWebPage":          true,
+	"NewsMediaOrganization":   true,
+	"Organization":            true,
+	"ProfilePage":             true,
+	"QAPage":                  true,
+	"SearchAction":            true,
+	"SearchResultsPage":       true,
+	"SiteNavigationElement":   true,
+	"WPFooter":                true,
+	"WPHeader":                true,
+	"WPSideBar":               true,
+	"WebPage":                 true,
+	"WebSite":                 true,
+}
+
+// ParseJSONLD will parse the JSON-LD structured data of the document, returning its primary entity
+// Each script of type application/ld+json is parsed, including any @graph arrays. Nil is returned if the document has no usable entities.
+// If the document has its final URL set, such as when fetched by a Client, URL properties such as image and url are resolved against the base URL of the document.
+func ParseJSONLD(doc *goquery.Document) *Entity {
+	var nodes []map[string]interface{}
+	base := documentBaseURL(doc)
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(index int, selection *goquery.Selection) { // For each JSON-LD script
+		var data interface{}
+
+		if jsonErr := json.Unmarshal([]byte(strings.TrimSpace(selection.Text())), &data); jsonErr == nil { // Ignore any invalid JSON, which is unfortunately common
+			if base != nil { // If we have a base URL to resolve URL properties against
+				resolveJSONLDURLs(data, base)
+			}
+
+			nodes = append(nodes, flattenJSONLD(data)...)
+		}
+	})
+
+	if len(nodes) == 0 { // No entities
+		return nil
+	}
+
+	nodesByID := make(map[string]map[string]interface{}) // Nodes by their @id, so we can resolve references such as "author": {"@id": "#person"}
+
+	for _, node := range nodes {
+		if id := jsonLDString(node["@id"]); id != "" {
+			nodesByID[id] = node
+		}
+	}
+
+	primary := primaryJSONLDNode(nodes, nodesByID)
+	return newEntity(primary, nodesByID)
+}
+
+// flattenJSONLD will flatten the provided JSON-LD data into a list of nodes, expanding arrays and @graph
+func flattenJSONLD(data interface{}) (nodes []map[string]interface{}) {
+	switch value := data.(type) {
+	case []interface{}: // Array of nodes
+		for _, item := range value {
+			nodes = append(nodes, flattenJSONLD(item)...)
+		}
+	case map[string]interface{}: // Single node, which may have a @graph
+		if graph, hasGraph := value["@graph"]; hasGraph {
+			nodes = append(nodes, flattenJSONLD(graph)...)
+		}
+
+		if _, hasType := value["@type"]; hasType {
+			nodes = append(nodes, value)
+		}
+	}
+
+	return
+}
+
+// primaryJSONLDNode will get the node which describes the primary content of the page
+// A WebPage with a mainEntity is resolved to that entity. Otherwise the first node which is not a supporting type is used.
+func primaryJSONLDNode(nodes []map[string]interface{}, nodesByID map[string]map[string]interface{}) map[string]interface{} {
+	for _, node := range nodes { // Prefer an explicitly declared main entity
+		if mainEntity := resolveJSONLDNode(node["mainEntity"], nodesByID); mainEntity != nil {
+			return mainEntity
+		}
+	}
+
+	for _, node := range nodes { // Otherwise use the first node describing content
+		if !supportingEntityTypes[jsonLDType(node)] {
+			return node
+		}
+	}
+
+	return nodes[0]
+}
+
+// newEntity will create an Entity from the provided JSON-LD node
+func newEntity(node map[string]interface{}, nodesByID map[string]map[string]interface{}) (entity *Entity) {
+	entity = &Entity{
+		Author:        jsonLDNames(node["author"], nodesByID),
+		DatePublished: firstNonEmpty(jsonLDString(node["datePublished"]), jsonLDString(node["startDate"]), jsonLDString(node["uploadDate"])),
+		Description:   jsonLDString(node["description"]),
+		Duration:      jsonLDString(node["duration"]),
+		Headline:      firstNonEmpty(jsonLDString(node["headline"]), jsonLDString(node["name"])),
+		Image:         firstNonEmpty(jsonLDURL(node["image"], nodesByID), jsonLDURL(node["thumbnailUrl"], nodesByID)),
+		Price:         jsonLDString(node["price"]),
+		Properties:    node,
+		Type:          jsonLDType(node),
+	}
+
+	if offers := resolveJSONLDNode(node["offers"], nodesByID); offers != nil { // Product, event or similar with offers
+		entity.Currency = jsonLDString(offers["priceCurrency"])
+		entity.Price = firstNonEmpty(jsonLDString(offers["price"]), jsonLDString(offers["lowPrice"]), entity.Price)
+	}
+
+	if rating := resolveJSONLDNode(node["aggregateRating"], nodesByID); rating != nil { // Has ratings
+		entity.Rating = jsonLDString(rating["ratingValue"])
+		entity.RatingCount = firstNonEmpty(jsonLDString(rating["ratingCount"]), jsonLDString(rating["reviewCount"]))
+	}
+
+	return
+}
+
+// firstNonEmpty will return the first of the provided strings which is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// jsonLDNames will get the name of the provided value, which may be a string, node, reference or array of these
+// Multiple names are joined with a comma.
+func jsonLDNames(value interface{}, nodesByID map[string]map[string]interface{}) string {
+	if values, isArray := value.([]interface{}); isArray { // Multiple, such as several authors
+		var names []string
+
+		for _, item := range values {
+			if name := jsonLDNames(item, nodesByID); name != "" {
+				names = append(names, name)
+			}
+		}
+
+		return strings.Join(names, ", ")
+	}
+
+	if node := resolveJSONLDNode(value, nodesByID); node != nil { // Person, Organization or reference to one
+		return jsonLDString(node["name"])
+	}
+
+	return jsonLDString(value)
+}
+
+// jsonLDString will get the provided value as a string, converting numbers and using the first item of arrays
+func jsonLDString(value interface{}) string {
+	switch typedValue := value.(type) {
+	case string:
+		return strings.TrimSpace(typedValue)
+	case float64:
+		return strconv.FormatFloat(typedValue, 'f', -1, 64)
+	case []interface{}:
+		if len(typedValue) > 0 {
+			return jsonLDString(typedValue[0])
+		}
+	}
+
+	return ""
+}
+
+// jsonLDType will get the @type of the provided node, using the first type if there are multiple
+func jsonLDType(node map[string]interface{}) string {
+	return trimSchemaPrefix(jsonLDString(node["@type"]))
+}
+
+// jsonLDURL will get the URL of the provided value, which may be a string, ImageObject, reference or array of these
+func jsonLDURL(value interface{}, nodesByID map[string]map[string]interface{}) string {
+	if values, isArray := value.([]interface{}); isArray { // Multiple, use the first
+		if len(values) == 0 {
+			return ""
+		}
+
+		return jsonLDURL(values[0], nodesByID)
+	}
+
+	if node := resolveJSONLDNode(value, nodesByID); node != nil { // ImageObject or reference to one
+		return firstNonEmpty(jsonLDString(node["url"]), jsonLDString(node["contentUrl"]))
+	}
+
+	return jsonLDString(value)
+}
+
+// resolveJSONLDURLs will resolve the URL properties of the provided JSON-LD data and any nested nodes against the base URL, in place
+func resolveJSONLDURLs(data interface{}, base *url.URL) {
+	switch value := data.(type) {
+	case []interface{}:
+		for _, item := range value {
+			resolveJSONLDURLs(item, base)
+		}
+	case map[string]interface{}:
+		for property, propertyValue := range value {
+			if !jsonLDURLProperties[property] { // Not a URL, but may contain nodes with URLs
+				resolveJSONLDURLs(propertyValue, base)
+				continue
+			}
+
+			switch urlValue := propertyValue.(type) {
+			case string:
+				value[property] = ResolveURL(base, urlValue)
+			case []interface{}:
+				for index, item := range urlValue {
+					if itemURL, isString := item.(string); isString {
+						urlValue[index] = ResolveURL(base, itemURL)
+					} else {
+						resolveJSONLDURLs(item, base)
+					}
+				}
+			default: // Such as an ImageObject
+				resolveJSONLDURLs(urlValue, base)
+			}
+		}
+	}
+}
+
+// resolveJSONLDNode will get the provided value as a node, resolving any @id reference and using the first node of arrays
+// Nil is returned if the value is not a node.
+func resolveJSONLDNode(value interface{}, nodesByID map[string]map[string]interface{}) map[string]interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		if id := jsonLDString(typedValue["@id"]); id != "" && len(typedValue) == 1 { // Only a reference to another node
+			if referenced, exists := nodesByID[id]; exists {
+				return referenced
+			}
+		}
+
+		return typedValue
+	case []interface{}:
+		if len(typedValue) > 0 {
+			return resolveJSONLDNode(typedValue[0], nodesByID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package sauron
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// This file contains our in-memory Cache
+
+// MemoryCache is an in-memory Cache which evicts the least recently used entry once it reaches its maximum number of entries
+type MemoryCache struct {
+	entries    map[string]*list.Element
+	maxEntries int
+	mutex      sync.Mutex
+	recency    *list.List // recency is our keys from most to least recently used
+}
+
+// memoryCacheItem is an entry of our MemoryCache, with its key so it may be removed on eviction
+type memoryCacheItem struct {
+	entry *CacheEntry
+	key   string
+}
+
+// NewMemoryCache will create a new MemoryCache holding up to maxEntries entries
+// A maxEntries of 0 or less does not limit the number of entries, though entries are still removed once they can no longer be used.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		entries:    make(map[string]*list.Element),
+		maxEntries: maxEntries,
+		recency:    list.New(),
+	}
+}
+
+// Delete will remove the entry for the key, if any
+func (cache *MemoryCache) Delete(key string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, exists := cache.entries[key]; exists {
+		cache.remove(element)
+	}
+}
+
+// Get will get the entry for the key, if any, marking it as recently used
+// Entries which are past their StaleUntil are removed rather than returned.
+func (cache *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, exists := cache.entries[key]
+
+	if !exists {
+		return nil, false
+	}
+
+	item := element.Value.(*memoryCacheItem)
+
+	if !time.Now().Before(item.entry.StaleUntil) { // Can no longer be used
+		cache.remove(element)
+		return nil, false
+	}
+
+	cache.recency.MoveToFront(element)
+	return item.entry, true
+}
+
+// Len will get the number of entries in the cache
+func (cache *MemoryCache) Len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return cache.recency.Len()
+}
+
+// Set will store the entry for the key, evicting the least recently used entry if the cache is full
+func (cache *MemoryCache) Set(key string, entry *CacheEntry) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, exists := cache.entries[key]; exists { // Replace our existing entry
+		element.Value.(*memoryCacheItem).entry = entry
+		cache.recency.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.recency.PushFront(&memoryCacheItem{entry: entry, key: key})
+
+	if cache.maxEntries > 0 && cache.recency.Len() > cache.maxEntries { // Full, so evict our least recently used entry
+		cache.remove(cache.recency.Back())
+	}
+}
+
+// remove will remove the provided element from our entries
+func (cache *MemoryCache) remove(element *list.Element) {
+	cache.recency.Remove(element)
+	delete(cache.entries, element.Value.(*memoryCacheItem).key)
+}
@@ -0,0 +1,227 @@
+package sauron
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"net/url"
+	"strings"
+)
+
+// This file contains our microdata and RDFa structured data parsing
+
+var (
+	// microdataSyntax is the attributes used by schema.org microdata, such as itemscope, itemtype and itemprop
+	microdataSyntax = structuredDataSyntax{idAttr: "itemid", propertyAttr: "itemprop", scopeAttr: "itemscope", source: "microdata", typeAttr: "itemtype"}
+
+	// rdfaSyntax is the attributes used by RDFa Lite, such as typeof and property
+	rdfaSyntax = structuredDataSyntax{idAttr: "resource", propertyAttr: "property", scopeAttr: "typeof", source: "rdfa", typeAttr: "typeof"}
+)
+
+// schemaPrefixes are the prefixes of schema.org types and properties which are trimmed, so https://schema.org/Product is simply Product
+var schemaPrefixes = []string{"http://schema.org/", "https://schema.org/", "schema:"}
+
+// Item is an item from the microdata or RDFa of a page, such as a Product or VideoObject
+type Item struct {
+	// ID is the global identifier of the item, from itemid or resource
+	ID string
+
+	// Properties is the properties of the item by name. A property may have multiple values, and each value may be text or a nested Item.
+	Properties map[string][]ItemValue
+
+	// Source is the syntax the item was parsed from, either microdata or rdfa
+	Source string
+
+	// Types is the types of the item, such as Product
+	Types []string
+}
+
+// ItemValue is a value of an Item property, which is either text or a nested Item
+type ItemValue struct {
+	Item *Item
+	Text string
+}
+
+// structuredDataSyntax is the attributes used by a structured data syntax
+type structuredDataSyntax struct {
+	idAttr, propertyAttr, scopeAttr, source, typeAttr string
+}
+
+// ParseItems will parse the microdata and RDFa of the document, returning its top-level items
+// Nested items are available through the properties of their parent item.
+// If the document has its final URL set, such as when fetched by a Client, URL values such as img src and a href are resolved against the base URL of the document.
+func ParseItems(doc *goquery.Document) (items []*Item) {
+	base := documentBaseURL(doc)
+
+	for _, syntax := range []structuredDataSyntax{microdataSyntax, rdfaSyntax} { // For each syntax
+		doc.Find("[" + syntax.scopeAttr + "]").Each(func(index int, selection *goquery.Selection) { // For each item
+			if _, isProperty := selection.Attr(syntax.propertyAttr); !isProperty { // Not a nested item of another item
+				items = append(items, syntax.parseItem(selection, base))
+			}
+		})
+	}
+
+	return
+}
+
+// Get will get the first text value of the property, or an empty string if it has none
+func (item *Item) Get(name string) string {
+	for _, value := range item.Properties[name] {
+		if value.Item == nil {
+			return value.Text
+		}
+	}
+
+	return ""
+}
+
+// GetItem will get the first nested item of the property, or nil if it has none
+func (item *Item) GetItem(name string) *Item {
+	for _, value := range item.Properties[name] {
+		if value.Item != nil {
+			return value.Item
+		}
+	}
+
+	return nil
+}
+
+// toJSONLD will convert the item to the form of a JSON-LD node, so it may be used for an Entity
+func (item *Item) toJSONLD() map[string]interface{} {
+	node := make(map[string]interface{})
+
+	if item.ID != "" {
+		node["@id"] = item.ID
+	}
+
+	if len(item.Types) != 0 {
+		types := make([]interface{}, len(item.Types))
+
+		for index, itemType := range item.Types {
+			types[index] = itemType
+		}
+
+		node["@type"] = types
+	}
+
+	for name, values := range item.Properties { // For each property
+		converted := make([]interface{}, len(values))
+
+		for index, value := range values {
+			if value.Item != nil { // Nested item
+				converted[index] = value.Item.toJSONLD()
+			} else {
+				converted[index] = value.Text
+			}
+		}
+
+		if len(converted) == 1 { // Single value, as is most common in JSON-LD
+			node[name] = converted[0]
+		} else {
+			node[name] = converted
+		}
+	}
+
+	return node
+}
+
+// entityFromItems will get the primary entity of the provided items, or nil if there are none
+func entityFromItems(items []*Item) *Entity {
+	if len(items) == 0 { // No items
+		return nil
+	}
+
+	nodes := make([]map[string]interface{}, len(items))
+
+	for index, item := range items {
+		nodes[index] = item.toJSONLD()
+	}
+
+	return newEntity(primaryJSONLDNode(nodes, nil), nil)
+}
+
+// itemValueText will get the text value of a property element
+// This is the content attribute if set, otherwise the URL or machine-readable value for elements which have one, such as img and time, falling back to the element text.
+// URLs are resolved against the provided base URL, if any.
+func itemValueText(selection *goquery.Selection, base *url.URL) string {
+	if content, hasContent := selection.Attr("content"); hasContent {
+		return strings.TrimSpace(content)
+	}
+
+	var valueAttr string
+
+	switch goquery.NodeName(selection) {
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		valueAttr = "src"
+	case "a", "area", "link":
+		valueAttr = "href"
+	case "object":
+		valueAttr = "data"
+	case "data", "meter":
+		valueAttr = "value"
+	case "time":
+		valueAttr = "datetime"
+	}
+
+	if value, hasValue := selection.Attr(valueAttr); valueAttr != "" && hasValue {
+		if base != nil && valueAttr != "value" && valueAttr != "datetime" { // Value is a URL and we have a base URL to resolve it against
+			return ResolveURL(base, value)
+		}
+
+		return strings.TrimSpace(value)
+	}
+
+	return strings.Join(strings.Fields(selection.Text()), " ") // Collapse any excessive whitespace
+}
+
+// trimSchemaPrefix will trim any schema.org prefix from the type or property name
+func trimSchemaPrefix(name string) string {
+	for _, prefix := range schemaPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+
+	return name
+}
+
+// parseItem will parse the item of the provided element, resolving any URL values against the provided base URL
+func (syntax structuredDataSyntax) parseItem(selection *goquery.Selection, base *url.URL) (item *Item) {
+	item = &Item{
+		ID:         selection.AttrOr(syntax.idAttr, ""),
+		Properties: make(map[string][]ItemValue),
+		Source:     syntax.source,
+	}
+
+	for _, itemType := range strings.Fields(selection.AttrOr(syntax.typeAttr, "")) {
+		item.Types = append(item.Types, trimSchemaPrefix(itemType))
+	}
+
+	syntax.parseProperties(item, selection.Children(), base)
+	return
+}
+
+// parseProperties will add the properties of the provided elements and their descendants to the item
+// Elements which begin a new item are not descended into, as their properties belong to that item.
+func (syntax structuredDataSyntax) parseProperties(item *Item, children *goquery.Selection, base *url.URL) {
+	children.Each(func(index int, child *goquery.Selection) {
+		_, isItem := child.Attr(syntax.scopeAttr)
+
+		if names := strings.Fields(child.AttrOr(syntax.propertyAttr, "")); len(names) != 0 { // Element is a property
+			var value ItemValue
+
+			if isItem { // Property is a nested item
+				value.Item = syntax.parseItem(child, base)
+			} else {
+				value.Text = itemValueText(child, base)
+			}
+
+			for _, name := range names {
+				name = trimSchemaPrefix(name)
+				item.Properties[name] = append(item.Properties[name], value)
+			}
+		}
+
+		if !isItem { // Not a new item, so its descendants may be properties of ours
+			syntax.parseProperties(item, child.Children(), base)
+		}
+	})
+}
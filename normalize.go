@@ -0,0 +1,180 @@
+package sauron
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// This file contains our URL normalization, used for stripping tracking parameters and deduplicating URLs
+
+// defaultPorts is the default port of each scheme, which are dropped during normalization
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalizer is a set of rules for normalizing URLs
+// A Normalizer should not be modified while it is in use by a Client.
+type Normalizer struct {
+	// HostParams is additional query parameters to strip for specific hosts, keyed by host. Subdomains of the host also match.
+	// Entries use the same format as StripParams.
+	HostParams map[string][]string
+
+	// KeepFragments indicates fragments should be kept. By default fragments are dropped, unless they are a hashbang (#!) route.
+	KeepFragments bool
+
+	// KeepQueryOrder indicates query parameters should keep their order. By default they are sorted by name.
+	KeepQueryOrder bool
+
+	// StripParams is query parameters to strip from all URLs. Matching of names is case-insensitive.
+	// Each entry may be an exact name (fbclid), a prefix ending in * (utm_*) or a name and value (feature=share).
+	StripParams []string
+}
+
+// NewNormalizer will create a new Normalizer with our default rules
+// This strips common tracking parameters from all URLs, as well as share parameters of YouTube, Reddit and Twitch.
+func NewNormalizer() *Normalizer {
+	return &Normalizer{
+		HostParams: map[string][]string{
+			"reddit.com":  {"rdt", "ref", "ref_source", "share_id"},
+			"twitch.tv":   {"referrer", "tt_*"},
+			"youtu.be":    {"feature", "pp"},
+			"youtube.com": {"ab_channel", "feature", "pp"},
+		},
+		StripParams: []string{
+			"_ga", "dclid", "fbclid", "feature=share", "gclid", "igshid", "mc_cid", "mc_eid", "msclkid", "ref_src", "si", "utm_*", "yclid",
+		},
+	}
+}
+
+// Normalize will normalize the provided URL, returning a normalized copy
+// The scheme and host are lowercased, default ports are dropped, tracking parameters are stripped, query parameters are sorted and fragments are dropped.
+func (normalizer *Normalizer) Normalize(u *url.URL) *url.URL {
+	normalized := *u
+	normalized.Scheme = strings.ToLower(normalized.Scheme)
+	normalized.Host = strings.ToLower(normalized.Host)
+
+	if host, port, splitErr := net.SplitHostPort(normalized.Host); splitErr == nil && defaultPorts[normalized.Scheme] == port { // If we have the default port of our scheme
+		normalized.Host = host
+
+		if strings.Contains(host, ":") { // IPv6, so re-add the brackets
+			normalized.Host = "[" + host + "]"
+		}
+	}
+
+	if normalized.Host != "" && normalized.Path == "" && normalized.Opaque == "" { // Ensure https://example.com and https://example.com/ are the same
+		normalized.Path = "/"
+		normalized.RawPath = ""
+	}
+
+	normalized.RawQuery = normalizer.normalizeQuery(normalized.Hostname(), normalized.RawQuery)
+	normalized.ForceQuery = false
+
+	if !normalizer.KeepFragments && !strings.HasPrefix(normalized.Fragment, "!") { // Drop fragments other than hashbang routes
+		normalized.Fragment = ""
+		normalized.RawFragment = ""
+	}
+
+	return &normalized
+}
+
+// NormalizeString will parse and normalize the provided URL
+func (normalizer *Normalizer) NormalizeString(rawURL string) (string, error) {
+	u, parseErr := url.Parse(rawURL)
+
+	if parseErr != nil {
+		return "", parseErr
+	}
+
+	return normalizer.Normalize(u).String(), nil
+}
+
+// hostParams will get the additional parameters to strip for the provided host
+func (normalizer *Normalizer) hostParams(host string) (params []string) {
+	for ruleHost, ruleParams := range normalizer.HostParams { // For each host rule
+		if hostMatches(host, ruleHost) {
+			params = append(params, ruleParams...)
+		}
+	}
+
+	return
+}
+
+// normalizeQuery will strip parameters from the raw query and sort it, keeping the original encoding of each parameter
+func (normalizer *Normalizer) normalizeQuery(host, rawQuery string) string {
+	if rawQuery == "" { // No query
+		return ""
+	}
+
+	stripParams := append(normalizer.hostParams(host), normalizer.StripParams...)
+
+	type queryParam struct {
+		name, raw string
+	}
+
+	var params []queryParam
+
+	for _, raw := range strings.FieldsFunc(rawQuery, func(r rune) bool { return r == '&' }) { // For each parameter, ignoring empty ones
+		rawName, rawValue := raw, ""
+
+		if separator := strings.Index(raw, "="); separator != -1 { // Has a value
+			rawName, rawValue = raw[:separator], raw[separator+1:]
+		}
+
+		name, nameErr := url.QueryUnescape(rawName)
+		value, valueErr := url.QueryUnescape(rawValue)
+
+		if nameErr != nil || valueErr != nil { // Keep parameters we can not decode as they are
+			name, value = rawName, rawValue
+		}
+
+		if !matchesParam(stripParams, name, value) { // Not a parameter we should strip
+			params = append(params, queryParam{name: name, raw: raw})
+		}
+	}
+
+	if !normalizer.KeepQueryOrder { // Sort by name, keeping the order of parameters with the same name
+		sort.SliceStable(params, func(i, j int) bool {
+			return params[i].name < params[j].name
+		})
+	}
+
+	rawParams := make([]string, len(params))
+
+	for index, param := range params {
+		rawParams[index] = param.raw
+	}
+
+	return strings.Join(rawParams, "&")
+}
+
+// hostMatches will check if the provided host is the rule host, or a subdomain of it
+func hostMatches(host, ruleHost string) bool {
+	ruleHost = strings.ToLower(ruleHost)
+	return host == ruleHost || strings.HasSuffix(host, "."+ruleHost)
+}
+
+// matchesParam will check if the provided parameter name and value match any of the rules
+func matchesParam(rules []string, name, value string) bool {
+	name = strings.ToLower(name)
+
+	for _, rule := range rules { // For each rule
+		rule = strings.ToLower(rule)
+
+		if separator := strings.Index(rule, "="); separator != -1 { // Name and value
+			if name == rule[:separator] && strings.EqualFold(value, rule[separator+1:]) {
+				return true
+			}
+		} else if strings.HasSuffix(rule, "*") { // Prefix
+			if strings.HasPrefix(name, strings.TrimSuffix(rule, "*")) {
+				return true
+			}
+		} else if name == rule { // Exact name
+			return true
+		}
+	}
+
+	return false
+}
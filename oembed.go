@@ -0,0 +1,222 @@
+package sauron
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/PuerkitoBio/goquery"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This file contains our oEmbed consumer, which enriches links using provider discovery and our bundled provider registry
+
+// maxOEmbedSize is the maximum number of bytes read from an oEmbed response
+const maxOEmbedSize = 1 << 20 // 1 MiB
+
+// OEmbed is an oEmbed response, as described by https://oembed.com
+type OEmbed struct {
+	AuthorName      string `json:"author_name,omitempty" xml:"author_name,omitempty"`
+	AuthorURL       string `json:"author_url,omitempty" xml:"author_url,omitempty"`
+	CacheAge        int    `json:"cache_age,omitempty" xml:"cache_age,omitempty"`
+	Height          int    `json:"height,omitempty" xml:"height,omitempty"`
+	HTML            string `json:"html,omitempty" xml:"html,omitempty"`
+	ProviderName    string `json:"provider_name,omitempty" xml:"provider_name,omitempty"`
+	ProviderURL     string `json:"provider_url,omitempty" xml:"provider_url,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty" xml:"thumbnail_height,omitempty"`
+	ThumbnailURL    string `json:"thumbnail_url,omitempty" xml:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty" xml:"thumbnail_width,omitempty"`
+	Title           string `json:"title,omitempty" xml:"title,omitempty"`
+	Type            string `json:"type" xml:"type"`
+	URL             string `json:"url,omitempty" xml:"url,omitempty"`
+	Version         string `json:"version" xml:"version"`
+	Width           int    `json:"width,omitempty" xml:"width,omitempty"`
+}
+
+// OEmbedProvider is an oEmbed provider, with the URL schemes it supports and its endpoint
+type OEmbedProvider struct {
+	// Endpoint is the oEmbed endpoint of the provider, such as https://vimeo.com/api/oembed.json
+	Endpoint string
+
+	Name string
+
+	// Schemes is the URL patterns supported by the provider, where * matches any characters, such as https://vimeo.com/*
+	Schemes []string
+
+	patterns []*regexp.Regexp // patterns is our compiled Schemes, if created with NewOEmbedProvider
+}
+
+// NewOEmbedProvider will create a new OEmbedProvider with the provided name, endpoint and URL schemes
+// The schemes are compiled once, rather than on every call to Matches, so the schemes should not be changed afterwards.
+func NewOEmbedProvider(name, endpoint string, schemes ...string) (provider OEmbedProvider) {
+	provider = OEmbedProvider{Endpoint: endpoint, Name: name, Schemes: schemes}
+
+	for _, scheme := range schemes {
+		provider.patterns = append(provider.patterns, compileOEmbedScheme(scheme))
+	}
+
+	return
+}
+
+// DefaultOEmbedProviders will get our bundled oEmbed providers
+func DefaultOEmbedProviders() []OEmbedProvider {
+	return []OEmbedProvider{
+		NewOEmbedProvider("Dailymotion", "https://www.dailymotion.com/services/oembed", "https://www.dailymotion.com/video/*", "https://dai.ly/*"),
+		NewOEmbedProvider("Flickr", "https://www.flickr.com/services/oembed/", "https://*.flickr.com/photos/*", "https://flic.kr/p/*"),
+		NewOEmbedProvider("SoundCloud", "https://soundcloud.com/oembed", "https://soundcloud.com/*", "https://on.soundcloud.com/*"),
+		NewOEmbedProvider("Spotify", "https://open.spotify.com/oembed", "https://open.spotify.com/*", "spotify:*"),
+		NewOEmbedProvider("TikTok", "https://www.tiktok.com/oembed", "https://www.tiktok.com/*/video/*"),
+		NewOEmbedProvider("Twitter", "https://publish.twitter.com/oembed", "https://twitter.com/*/status/*", "https://x.com/*/status/*"),
+		NewOEmbedProvider("Vimeo", "https://vimeo.com/api/oembed.json", "https://vimeo.com/*", "https://player.vimeo.com/video/*"),
+	}
+}
+
+// UnmarshalJSON will parse an oEmbed response
+// Providers are inconsistent about whether dimensions are numbers or strings, so both are accepted.
+func (embed *OEmbed) UnmarshalJSON(content []byte) error {
+	var fields map[string]interface{}
+
+	if jsonErr := json.Unmarshal(content, &fields); jsonErr != nil {
+		return jsonErr
+	}
+
+	*embed = OEmbed{
+		AuthorName:      jsonLDString(fields["author_name"]),
+		AuthorURL:       jsonLDString(fields["author_url"]),
+		CacheAge:        oEmbedInt(fields["cache_age"]),
+		Height:          oEmbedInt(fields["height"]),
+		HTML:            jsonLDString(fields["html"]),
+		ProviderName:    jsonLDString(fields["provider_name"]),
+		ProviderURL:     jsonLDString(fields["provider_url"]),
+		ThumbnailHeight: oEmbedInt(fields["thumbnail_height"]),
+		ThumbnailURL:    jsonLDString(fields["thumbnail_url"]),
+		ThumbnailWidth:  oEmbedInt(fields["thumbnail_width"]),
+		Title:           jsonLDString(fields["title"]),
+		Type:            jsonLDString(fields["type"]),
+		URL:             jsonLDString(fields["url"]),
+		Version:         jsonLDString(fields["version"]),
+		Width:           oEmbedInt(fields["width"]),
+	}
+
+	return nil
+}
+
+// Matches will check if the provided URL matches one of the provider's schemes
+// The scheme of the URL is ignored, so http and https URLs both match.
+// Providers not created with NewOEmbedProvider have their schemes compiled on each call.
+func (provider OEmbedProvider) Matches(u *url.URL) bool {
+	target := strings.TrimPrefix(strings.TrimPrefix(u.String(), "https://"), "http://")
+	patterns := provider.patterns
+
+	if len(patterns) != len(provider.Schemes) { // Not created with NewOEmbedProvider
+		patterns = NewOEmbedProvider(provider.Name, provider.Endpoint, provider.Schemes...).patterns
+	}
+
+	for _, pattern := range patterns { // For each scheme
+		if pattern.MatchString(target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compileOEmbedScheme will compile the provided oEmbed URL scheme, ignoring its http or https scheme, where * matches any characters
+func compileOEmbedScheme(scheme string) *regexp.Regexp {
+	scheme = strings.TrimPrefix(strings.TrimPrefix(scheme, "https://"), "http://")
+	return regexp.MustCompile("^" + strings.Replace(regexp.QuoteMeta(scheme), `\*`, ".*", -1) + "$") // Quoted, so always compiles
+}
+
+// mergeOEmbed will merge the provided oEmbed response into the link
+// The embed is available as Link.OEmbed, and its HTML, dimensions, author and provider are added to our Extras.
+// The title and image of the link are only set from the embed if the page did not provide them.
+func mergeOEmbed(link *Link, embed *OEmbed) {
+	link.OEmbed = embed
+
+	if link.Extras == nil {
+		link.Extras = make(map[string]string)
+	}
+
+	if link.Title == "" {
+		link.Title = embed.Title
+	}
+
+	if link.Image == "" { // Use the thumbnail
+		link.Image = embed.ThumbnailURL
+	}
+
+	if link.Image == "" && embed.Type == "photo" { // Use the photo itself
+		link.Image = embed.URL
+	}
+
+	extras := map[string]string{
+		"AuthorName":   embed.AuthorName,
+		"AuthorURL":    embed.AuthorURL,
+		"EmbedHTML":    embed.HTML,
+		"EmbedType":    embed.Type,
+		"ProviderName": embed.ProviderName,
+		"ProviderURL":  embed.ProviderURL,
+	}
+
+	if embed.Height > 0 && embed.Width > 0 { // Has dimensions
+		extras["EmbedHeight"] = strconv.Itoa(embed.Height)
+		extras["EmbedWidth"] = strconv.Itoa(embed.Width)
+	}
+
+	for extrasType, value := range extras { // For each of our extras
+		if _, exists := link.Extras[extrasType]; !exists && value != "" { // Do not override anything set by our parser
+			link.Extras[extrasType] = value
+		}
+	}
+}
+
+// oEmbedInt will get the provided oEmbed value as an int, accepting both numbers and strings
+func oEmbedInt(value interface{}) int {
+	number, _ := strconv.ParseFloat(jsonLDString(value), 64)
+	return int(number)
+}
+
+// discoverOEmbed will get the JSON oEmbed endpoint declared by the document, if any
+func discoverOEmbed(doc *goquery.Document, baseURL *url.URL) string {
+	return ResolveURL(baseURL, doc.Find(`link[rel~="alternate"][type="application/json+oembed"]`).First().AttrOr("href", ""))
+}
+
+// fetchOEmbed will fetch and parse the oEmbed response from the provided endpoint URL
+func (c *Client) fetchOEmbed(ctx context.Context, endpoint string) (embed *OEmbed, fetchErr error) {
+	content, _, fetchErr := c.fetchResource(ctx, endpoint, maxOEmbedSize)
+
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	embed = &OEmbed{}
+
+	if fetchErr = json.Unmarshal(content, embed); fetchErr != nil { // Not a valid oEmbed response
+		return nil, fetchErr
+	}
+
+	return
+}
+
+// oEmbedEndpoint will get the oEmbed endpoint URL for the provided page, using our providers or otherwise discovery from the document
+func (c *Client) oEmbedEndpoint(doc *goquery.Document, u *url.URL) string {
+	for _, provider := range c.OEmbedProviders { // For each of our providers
+		if provider.Matches(u) {
+			endpoint := strings.Replace(provider.Endpoint, "{format}", "json", -1)
+			separator := "?"
+
+			if strings.Contains(endpoint, "?") { // Endpoint already has a query
+				separator = "&"
+			}
+
+			return endpoint + separator + url.Values{"format": {"json"}, "url": {u.String()}}.Encode()
+		}
+	}
+
+	if doc != nil { // Fall back to discovery
+		return discoverOEmbed(doc, BaseURL(doc, u))
+	}
+
+	return ""
+}
@@ -0,0 +1,288 @@
+// Package oembed provides an oEmbed endpoint serving Sauron's link information as oEmbed responses, as described by https://oembed.com
+package oembed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/TryStreambits/sauron"
+	"github.com/TryStreambits/sauron/server"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultVideoHeight and defaultVideoWidth are the dimensions used for video embeds when the page does not provide any
+const (
+	defaultVideoHeight = 360
+	defaultVideoWidth  = 640
+)
+
+// dimensionAttrRegex matches the width and height attributes of embed HTML, so they may be scaled
+var dimensionAttrRegex = regexp.MustCompile(`\b(width|height)=(["']?)(\d+)(["']?)`)
+
+// Handler is an http.Handler serving oEmbed responses for the url query parameter, such as /oembed?url=https://example.com&format=json
+// The json and xml formats are supported, as well as the maxwidth and maxheight parameters.
+// See sauron.Client.EnableSafeDialer before exposing it publicly.
+type Handler struct {
+	// CacheAge is the suggested cache lifetime of responses in seconds. Zero omits it.
+	CacheAge int
+
+	// Client is the Sauron Client used for getting links. If nil, the DefaultClient is used.
+	Client *sauron.Client
+}
+
+// NewHandler will create a new Handler using the provided Client
+func NewHandler(client *sauron.Client) *Handler {
+	return &Handler{Client: client}
+}
+
+// FromLink will create an oEmbed response from the provided link, fitting any embed within maxWidth and maxHeight
+// Links enriched with oEmbed by their provider are passed through. Otherwise direct image links with known dimensions are a photo,
+// pages with an embeddable player or direct video links are a video, and anything else is a link. Photos require dimensions per the oEmbed spec.
+// Only absolute http and https URLs are used for photos, players, videos and thumbnails, as pages may otherwise provide javascript: or data: URLs which embedding sites would render.
+// A maxWidth or maxHeight of 0 or less is not applied.
+func FromLink(link *sauron.Link, maxWidth, maxHeight int) (embed *sauron.OEmbed) {
+	linkURL := link.FinalURL
+
+	if linkURL == "" { // No final URL, such as a link created by a parser directly
+		linkURL = link.URI
+	}
+
+	if link.OEmbed != nil { // Pass through our provider's response
+		passthrough := *link.OEmbed
+		embed = &passthrough
+	} else {
+		embed = &sauron.OEmbed{
+			AuthorName:   firstNonEmpty(link.Extras["AuthorName"], entityAuthor(link), link.Extras["Streamer"], link.Extras["TwitterCreator"]),
+			AuthorURL:    link.Extras["AuthorURL"],
+			ProviderName: firstNonEmpty(link.Extras["ProviderName"], link.Extras["SiteName"], link.Host),
+			ProviderURL:  firstNonEmpty(link.Extras["ProviderURL"], originOf(linkURL)),
+			Title:        link.Title,
+		}
+
+		imageWidth, imageHeight := atoiOr(link.Extras["ImageWidth"], 0), atoiOr(link.Extras["ImageHeight"], 0)
+		player := link.Extras["TwitterPlayer"]
+
+		if !isWebURL(player) && link.Extras["VideoType"] == "text/html" { // Open Graph video which is a player rather than a file
+			player = link.Extras["Video"]
+		}
+
+		if !isWebURL(player) { // No player we can safely embed
+			player = ""
+		}
+
+		switch {
+		case link.Extras["IsImageLink"] == "true" && imageWidth > 0 && imageHeight > 0 && isWebURL(linkURL): // Direct image link, such as from a parser which knows its dimensions
+			embed.Type = "photo"
+			embed.URL = linkURL
+			embed.Width = imageWidth
+			embed.Height = imageHeight
+		case player != "": // Page has an embeddable player
+			embed.Type = "video"
+			embed.URL = player
+			embed.Width = atoiOr(firstNonEmpty(link.Extras["TwitterPlayerWidth"], link.Extras["VideoWidth"]), defaultVideoWidth)
+			embed.Height = atoiOr(firstNonEmpty(link.Extras["TwitterPlayerHeight"], link.Extras["VideoHeight"]), defaultVideoHeight)
+		case link.Extras["IsVideoLink"] == "true" && isWebURL(linkURL): // Direct video link
+			embed.Type = "video"
+			embed.URL = linkURL
+			embed.Width = defaultVideoWidth
+			embed.Height = defaultVideoHeight
+		default:
+			embed.Type = "link"
+		}
+
+		if isWebURL(link.Image) && embed.Type != "photo" { // Use our image as the thumbnail
+			embed.ThumbnailURL = link.Image
+			embed.ThumbnailWidth = imageWidth
+			embed.ThumbnailHeight = imageHeight
+
+			if embed.ThumbnailWidth == 0 || embed.ThumbnailHeight == 0 { // Dimensions must be provided together
+				embed.ThumbnailWidth, embed.ThumbnailHeight = 0, 0
+			}
+		}
+	}
+
+	embed.Version = "1.0"
+	width, height := fit(embed.Width, embed.Height, maxWidth, maxHeight)
+
+	if link.OEmbed != nil && (width != embed.Width || height != embed.Height) { // Scale the provider's HTML to our new dimensions
+		embed.HTML = dimensionAttrRegex.ReplaceAllStringFunc(embed.HTML, func(attr string) string {
+			parts := dimensionAttrRegex.FindStringSubmatch(attr)
+			value := width
+
+			if parts[1] == "height" {
+				value = height
+			}
+
+			return fmt.Sprintf("%s=%s%d%s", parts[1], parts[2], value, parts[4])
+		})
+	}
+
+	embed.Width, embed.Height = width, height
+
+	if link.OEmbed == nil && embed.Type == "video" { // Create our embed HTML
+		if player := embed.URL; player != "" && link.Extras["IsVideoLink"] != "true" {
+			embed.HTML = fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" allowfullscreen></iframe>`, html.EscapeString(player), embed.Width, embed.Height)
+		} else {
+			embed.HTML = fmt.Sprintf(`<video src="%s" width="%d" height="%d" controls></video>`, html.EscapeString(embed.URL), embed.Width, embed.Height)
+		}
+
+		embed.URL = "" // Video responses use html rather than url
+	}
+
+	return
+}
+
+// ServeHTTP will serve the oEmbed response for the url query parameter
+// Responds with 400 if the url, maxwidth or maxheight are invalid and 501 if the format is not supported.
+// If the link could not be fetched, the status is the same as the server package would respond with, such as 504 for timeouts. See server.StatusForError.
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead { // Only GET is supported
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	target := query.Get("url")
+
+	if target == "" { // No URL to embed
+		http.Error(w, "Missing url parameter", http.StatusBadRequest)
+		return
+	}
+
+	format := strings.ToLower(query.Get("format"))
+
+	if format != "" && format != "json" && format != "xml" { // Not a format we support
+		http.Error(w, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
+		return
+	}
+
+	maxWidth, widthErr := parseDimension(query.Get("maxwidth"))
+	maxHeight, heightErr := parseDimension(query.Get("maxheight"))
+
+	if widthErr != nil || heightErr != nil { // Invalid dimensions
+		http.Error(w, "Invalid maxwidth or maxheight parameter", http.StatusBadRequest)
+		return
+	}
+
+	client := handler.Client
+
+	if client == nil { // No client provided
+		client = sauron.DefaultClient
+	}
+
+	link, linkErr := client.GetLinkContext(r.Context(), target)
+
+	if linkErr != nil { // Could not get link information for this URL
+		http.Error(w, linkErr.Error(), server.StatusForError(linkErr))
+		return
+	}
+
+	embed := FromLink(link, maxWidth, maxHeight)
+	embed.CacheAge = handler.CacheAge
+
+	if format == "xml" {
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		w.Write([]byte(xml.Header))
+		xml.NewEncoder(w).EncodeElement(embed, xml.StartElement{Name: xml.Name{Local: "oembed"}})
+	} else {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(embed)
+	}
+}
+
+// atoiOr will convert the provided string to an int, returning the fallback if it is not a positive integer
+func atoiOr(value string, fallback int) int {
+	if converted, convErr := strconv.Atoi(strings.TrimSpace(value)); convErr == nil && converted > 0 {
+		return converted
+	}
+
+	return fallback
+}
+
+// entityAuthor will get the author of the structured data entity of the link, if any
+func entityAuthor(link *sauron.Link) string {
+	if link.Entity == nil {
+		return ""
+	}
+
+	return link.Entity.Author
+}
+
+// firstNonEmpty will return the first of the provided strings which is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// fit will scale the provided dimensions to fit within the maximum dimensions, keeping their aspect ratio
+// Dimensions which are unknown, or already fit, are returned as is.
+func fit(width, height, maxWidth, maxHeight int) (int, int) {
+	if width <= 0 || height <= 0 { // Unknown dimensions
+		return width, height
+	}
+
+	scale := 1.0
+
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+
+	if maxHeight > 0 && float64(height)*scale > float64(maxHeight) {
+		scale = float64(maxHeight) / float64(height)
+	}
+
+	if scale == 1.0 { // Already fits
+		return width, height
+	}
+
+	return int(float64(width) * scale), int(float64(height) * scale)
+}
+
+// isWebURL will check if the provided URL is an absolute http or https URL
+func isWebURL(rawURL string) bool {
+	u, parseErr := url.Parse(rawURL)
+
+	if parseErr != nil || u.Host == "" {
+		return false
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "http" || scheme == "https"
+}
+
+// originOf will get the scheme and host of the provided URL, such as https://example.com
+func originOf(rawURL string) string {
+	u, parseErr := url.Parse(rawURL)
+
+	if parseErr != nil || u.Host == "" {
+		return ""
+	}
+
+	return u.Scheme + "://" + u.Host
+}
+
+// parseDimension will parse a maxwidth or maxheight parameter, where an empty value is 0
+func parseDimension(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	dimension, convErr := strconv.Atoi(value)
+
+	if convErr == nil && dimension < 0 {
+		convErr = fmt.Errorf("negative dimension %d", dimension)
+	}
+
+	return dimension, convErr
+}
@@ -1,15 +1,33 @@
 package sauron
 
 import (
+	"context"
 	"github.com/PuerkitoBio/goquery"
 	"net/url"
 	"strconv"
 )
 
+// Reddit is our internal Reddit parser, using the DefaultClient
+// This parser will get page information as well as Reddit post information such as dislikes, likes, and overall score
+func Reddit(doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return DefaultClient.RedditContext(context.Background(), doc, url, fullURL)
+}
+
+// RedditContext is our internal Reddit parser, using the DefaultClient and the provided context for any secondary requests
+func RedditContext(ctx context.Context, doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return DefaultClient.RedditContext(ctx, doc, url, fullURL)
+}
+
 // Reddit is our internal Reddit parser
 // This parser will get page information as well as Reddit post information such as dislikes, likes, and overall score
-func Reddit(doc *goquery.Document, url *url.URL, fullURL string) (link *Link, parserErr error) {
-	link, parserErr = Primitive(doc, url, fullURL) // First get our link information from Primitive
+func (c *Client) Reddit(doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return c.RedditContext(context.Background(), doc, url, fullURL)
+}
+
+// RedditContext is our internal Reddit parser, using the provided context for any secondary requests made by Primitive
+// This parser will get page information as well as Reddit post information such as dislikes, likes, and overall score
+func (c *Client) RedditContext(ctx context.Context, doc *goquery.Document, url *url.URL, fullURL string) (link *Link, parserErr error) {
+	link, parserErr = c.PrimitiveContext(ctx, doc, url, fullURL) // First get our link information from Primitive
 
 	link.Extras["IsRedditLink"] = "true" // Indicate it is a Reddit link
 
@@ -0,0 +1,209 @@
+package sauron
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// This file contains our parser registry, which is safe for concurrent registration and lookup
+
+// parserRegistry is our registry of hostnames to parsers
+// All access to the underlying maps is guarded, allowing parsers to be registered while requests are in flight
+type parserRegistry struct {
+	hasOverriddenInternals map[string]bool             // hasOverriddenInternals is a map of our internal parsers and if they have been overridden
+	hostToParsers          map[string]registeredParser // hostToParsers is our map of exact hostnames to parsers
+	mutex                  sync.RWMutex
+	regexParsers           []regexParser               // regexParsers is our list of regex patterns to parsers, in order of registration
+	wildcardToParsers      map[string]registeredParser // wildcardToParsers is our map of wildcard suffixes (such as .example.com) to parsers
+}
+
+// registeredParser is a parser along with the options it was registered with
+type registeredParser struct {
+	headOnly bool // headOnly indicates the parser only requires the document head
+	parser   ContextLinkParser
+}
+
+// regexParser is a parser registered against a regular expression
+type regexParser struct {
+	registeredParser
+	expression string
+	regex      *regexp.Regexp
+}
+
+// newRegisteredParser will create a registeredParser with the provided options applied
+func newRegisteredParser(parser ContextLinkParser, options []ParserOption) (registered registeredParser) {
+	registered.parser = parser
+
+	for _, option := range options {
+		if option == HeadOnly {
+			registered.headOnly = true
+		}
+	}
+
+	return
+}
+
+// regexCharacters are characters which indicate a hostname provided for registration is a regular expression
+const regexCharacters = `^$*+?()[]{}|\`
+
+// newParserRegistry will create a new parserRegistry with the provided internal parsers
+// Each of the provided hostnames is treated as an internal parser which can be overridden
+func newParserRegistry(internals map[string]registeredParser) (registry *parserRegistry) {
+	registry = &parserRegistry{
+		hasOverriddenInternals: make(map[string]bool),
+		hostToParsers:          make(map[string]registeredParser),
+		wildcardToParsers:      make(map[string]registeredParser),
+	}
+
+	for hostName, parser := range internals { // For each internal parser
+		registry.hasOverriddenInternals[hostName] = false
+		registry.hostToParsers[hostName] = parser
+	}
+
+	return
+}
+
+// get will get the parser registered for the provided hostname, if any
+// Exact matches take precedence over wildcards, which take precedence over regular expressions. Exact matches including the port are preferred.
+// Among wildcards the longest suffix wins, and among regular expressions the longest match wins, followed by the earliest registered.
+func (registry *parserRegistry) get(hostName string) (parser registeredParser, exists bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	hostName = strings.ToLower(hostName)
+
+	if parser, exists = registry.hostToParsers[hostName]; exists { // Exact match
+		return
+	}
+
+	if host, _, splitErr := net.SplitHostPort(hostName); splitErr == nil { // If the hostname has a port, ignore it for any further matching
+		hostName = host
+
+		if parser, exists = registry.hostToParsers[hostName]; exists { // Exact match without the port
+			return
+		}
+	}
+
+	for suffix := hostName; strings.Contains(suffix, "."); { // For each suffix of our hostname, from longest to shortest
+		suffix = suffix[strings.Index(suffix, "."):]
+
+		if parser, exists = registry.wildcardToParsers[suffix]; exists { // Wildcard match
+			return
+		}
+
+		suffix = suffix[1:] // Trim the leading period so we can continue to the next label
+	}
+
+	longestMatch := -1
+
+	for _, regexEntry := range registry.regexParsers { // For each regular expression, in order of registration
+		if loc := regexEntry.regex.FindStringIndex(hostName); loc != nil { // If this expression matches
+			if matchLength := loc[1] - loc[0]; matchLength > longestMatch { // If this is our longest match yet
+				longestMatch = matchLength
+				parser = regexEntry.registeredParser
+				exists = true
+			}
+		}
+	}
+
+	return
+}
+
+// hasOverridden will check if the internal parser for the provided hostname has been overridden
+func (registry *parserRegistry) hasOverridden(hostName string) bool {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	return registry.hasOverriddenInternals[hostName]
+}
+
+// register will register the provided parser for the hostname
+// The hostname may be an exact hostname (google.com), wildcard (*.google.com) or regular expression (^(www\.)?google\.(com|ca)$).
+// Hostnames and wildcards are lowercased, while regular expressions are compiled as provided and matched case-insensitively.
+// If force is true, any existing parser is replaced. Otherwise an existing parser will result in an error.
+func (registry *parserRegistry) register(hostName string, parser registeredParser, force bool) error {
+	var regex *regexp.Regexp
+
+	if isRegexHostName(hostName) { // If this is a regular expression, ensure it compiles before taking our lock
+		var compileErr error
+
+		if regex, compileErr = regexp.Compile("(?i)" + hostName); compileErr != nil { // Match case-insensitively rather than lowercasing, which would change escapes such as \D to \d
+			return compileErr
+		}
+	} else {
+		hostName = strings.ToLower(hostName)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if regex != nil { // Regular expression
+		for index, regexEntry := range registry.regexParsers { // For each existing expression
+			if regexEntry.expression == hostName { // If this expression has already been registered
+				if !force {
+					return ErrHostAlreadyRegistered
+				}
+
+				registry.regexParsers[index].registeredParser = parser // Replace in place so we retain registration order
+				return nil
+			}
+		}
+
+		registry.regexParsers = append(registry.regexParsers, regexParser{registeredParser: parser, expression: hostName, regex: regex})
+		return nil
+	}
+
+	if isWildcardHostName(hostName) { // Wildcard
+		suffix := strings.TrimPrefix(hostName, "*")
+
+		if _, registered := registry.wildcardToParsers[suffix]; registered && !force {
+			return ErrHostAlreadyRegistered
+		}
+
+		registry.wildcardToParsers[suffix] = parser
+		return nil
+	}
+
+	if _, registered := registry.hostToParsers[hostName]; registered && !force { // If this hostname has already been registered and we are not forcing
+		return ErrHostAlreadyRegistered
+	}
+
+	registry.hostToParsers[hostName] = parser // Add this parser
+
+	if _, hasOverrideValue := registry.hasOverriddenInternals[hostName]; hasOverrideValue { // Check if we have a respective entry in overridden internals for this hostname
+		registry.hasOverriddenInternals[hostName] = true
+	}
+
+	return nil
+}
+
+// unregister will unregister any parser for the provided hostname, wildcard or regular expression
+func (registry *parserRegistry) unregister(hostName string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if isRegexHostName(hostName) { // Regular expression, which is registered as provided
+		for index, regexEntry := range registry.regexParsers {
+			if regexEntry.expression == hostName {
+				registry.regexParsers = append(registry.regexParsers[:index:index], registry.regexParsers[index+1:]...) // Copy so we do not modify the slice in place
+				break
+			}
+		}
+	} else if isWildcardHostName(hostName) { // Wildcard
+		delete(registry.wildcardToParsers, strings.ToLower(strings.TrimPrefix(hostName, "*")))
+	} else {
+		delete(registry.hostToParsers, strings.ToLower(hostName))
+	}
+}
+
+// isRegexHostName will check if the provided hostname is a regular expression rather than an exact or wildcard hostname
+func isRegexHostName(hostName string) bool {
+	return !isWildcardHostName(hostName) && strings.ContainsAny(hostName, regexCharacters)
+}
+
+// isWildcardHostName will check if the provided hostname is a wildcard such as *.example.com
+func isWildcardHostName(hostName string) bool {
+	return strings.HasPrefix(hostName, "*.") && !strings.ContainsAny(hostName[2:], regexCharacters)
+}
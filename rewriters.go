@@ -0,0 +1,159 @@
+package sauron
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// This file contains our URL rewriters, which change the URL used for fetching a document prior to parsing
+
+// URLRewriter is a function which takes in a URL and returns the URL that should be used for fetching the document instead
+// Returning a nil URL indicates the rewriter does not apply to the provided URL.
+type URLRewriter func(*url.URL) (*url.URL, error)
+
+// namedRewriter is a URLRewriter registered under a specific name
+type namedRewriter struct {
+	name     string
+	rewriter URLRewriter
+}
+
+// rewriterRegistry is our registry of URL rewriters, applied in order of registration
+type rewriterRegistry struct {
+	mutex     sync.RWMutex
+	rewriters []namedRewriter
+}
+
+// newRewriterRegistry will create a new rewriterRegistry with our internal rewriters
+// Each internal rewriter only applies while the internal parser of the same name has not been overridden, as checked by hasOverridden.
+func newRewriterRegistry(hasOverridden func(host string) bool) *rewriterRegistry {
+	return &rewriterRegistry{
+		rewriters: []namedRewriter{
+			{name: "reddit.com", rewriter: internalRewriter("reddit.com", RedditRewriter, hasOverridden)},
+			{name: "youtu.be", rewriter: internalRewriter("youtu.be", YoutubeShortRewriter, hasOverridden)},
+			{name: "youtube.com", rewriter: internalRewriter("youtube.com", YoutubeRewriter, hasOverridden)},
+		},
+	}
+}
+
+// internalRewriter will wrap one of our internal rewriters so it does not apply once the internal parser for the host has been overridden
+// Our internal rewriters only exist to serve our internal parsers, so a custom parser receives the URL as provided.
+// Rewriters are otherwise independent of HasOverridden, via UnregisterRewriter and ForceRegisterRewriter. This link is kept for compatibility,
+// as prior to our rewriter pipeline, overriding an internal parser such as reddit.com also stopped its URL from being rewritten.
+func internalRewriter(host string, rewriter URLRewriter, hasOverridden func(host string) bool) URLRewriter {
+	return func(u *url.URL) (*url.URL, error) {
+		if hasOverridden(host) { // Our internal parser is no longer used for this host
+			return nil, nil
+		}
+
+		return rewriter(u)
+	}
+}
+
+// register will register the provided rewriter under the name
+// If force is true, any existing rewriter with the name is replaced in place. Otherwise an existing rewriter will result in an error.
+func (registry *rewriterRegistry) register(name string, rewriter URLRewriter, force bool) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for index, existing := range registry.rewriters { // For each existing rewriter
+		if existing.name == name { // If this name has already been registered
+			if !force {
+				return ErrRewriterAlreadyRegistered
+			}
+
+			rewriters := append([]namedRewriter(nil), registry.rewriters...) // Copy so any in-flight rewrite keeps its view of the pipeline
+			rewriters[index].rewriter = rewriter                             // Replace in place so we retain the order of our pipeline
+			registry.rewriters = rewriters
+			return nil
+		}
+	}
+
+	registry.rewriters = append(registry.rewriters, namedRewriter{name: name, rewriter: rewriter})
+	return nil
+}
+
+// rewrite will pass the provided URL through each of our rewriters, returning the URL to use for fetching the document
+func (registry *rewriterRegistry) rewrite(u *url.URL) (rewritten *url.URL, rewriteErr error) {
+	registry.mutex.RLock()
+	rewriters := registry.rewriters // Copy our slice header so we can release our lock before calling any rewriters
+	registry.mutex.RUnlock()
+
+	urlCopy := *u // Copy our URL so rewriters can not modify the original
+	rewritten = &urlCopy
+
+	for _, entry := range rewriters { // For each of our rewriters
+		var result *url.URL
+
+		if result, rewriteErr = entry.rewriter(rewritten); rewriteErr != nil { // Failed to rewrite
+			return
+		}
+
+		if result != nil { // If this rewriter applied
+			rewritten = result
+		}
+	}
+
+	return
+}
+
+// unregister will unregister the rewriter with the provided name
+func (registry *rewriterRegistry) unregister(name string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for index, existing := range registry.rewriters {
+		if existing.name == name {
+			registry.rewriters = append(registry.rewriters[:index:index], registry.rewriters[index+1:]...) // Copy so any in-flight rewrite keeps its view of the pipeline
+			break
+		}
+	}
+}
+
+// RedditRewriter will rewrite any reddit.com URL to use old.reddit.com, which our Reddit parser supports
+func RedditRewriter(u *url.URL) (*url.URL, error) {
+	if !strings.HasSuffix(u.Host, "reddit.com") || u.Host == "old.reddit.com" { // Not Reddit or already old.reddit.com
+		return nil, nil
+	}
+
+	return url.Parse(strings.Replace(u.String(), u.Host, "old.reddit.com", -1)) // Convert host to old.reddit.com
+}
+
+// YoutubeShortRewriter will rewrite shortened youtu.be URLs to their respective youtube.com watch URL
+func YoutubeShortRewriter(u *url.URL) (*url.URL, error) {
+	if u.Host != "youtu.be" { // Not the shortened YouTube URL
+		return nil, nil
+	}
+
+	videoPath := strings.TrimPrefix(u.Path, "/") // Trim the / from the start of the path
+
+	customQuery := u.Query()
+	customQuery.Set("disable_polymer", "true") // Disable polymer to get the full page content without JavaScript messiness
+	customQuery.Set("v", videoPath)
+
+	return &url.URL{
+		Host:     "youtube.com",
+		Path:     "/watch",
+		RawQuery: customQuery.Encode(),
+		Scheme:   "https",
+	}, nil
+}
+
+// YoutubeRewriter will rewrite YouTube subdomains, such as m.youtube.com and www.youtube.com, to youtube.com
+func YoutubeRewriter(u *url.URL) (*url.URL, error) {
+	if !strings.HasSuffix(u.Host, "youtube.com") || u.Host == "youtube.com" { // Not YouTube or already youtube.com
+		return nil, nil
+	}
+
+	normalYoutubeURL, parseErr := url.Parse(strings.Replace(u.String(), u.Host, "youtube.com", -1)) // Convert host to youtube.com
+
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	customQuery := normalYoutubeURL.Query()
+	customQuery.Set("disable_polymer", "true") // Disable polymer to get the full page content without JavaScript messiness
+	normalYoutubeURL.RawQuery = customQuery.Encode()
+
+	return normalYoutubeURL, nil
+}
@@ -0,0 +1,170 @@
+package sauron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// This file contains our SafeDialer, which protects against server-side request forgery by refusing to connect to internal addresses
+
+// ErrBlockedAddress is matched by any BlockedAddressError
+var ErrBlockedAddress = errors.New("Connection to address is not permitted")
+
+// BlockedAddressError is an error for when a SafeDialer refuses to connect to an address
+type BlockedAddressError struct {
+	Address string
+	IP      net.IP
+}
+
+// Error will return our error message, including the refused address
+func (blockedErr *BlockedAddressError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrBlockedAddress.Error(), blockedErr.Address)
+}
+
+// Is will indicate that our BlockedAddressError matches ErrBlockedAddress
+func (blockedErr *BlockedAddressError) Is(target error) bool {
+	return target == ErrBlockedAddress
+}
+
+// SafeDialer is a dialer which refuses to connect to private, loopback, link-local, multicast and unspecified addresses
+// The check is performed against the resolved IP at the time of connecting, so it applies to redirects and can not be bypassed by DNS rebinding.
+type SafeDialer struct {
+	// AllowedHosts is a list of hostnames which may be connected to regardless of the address they resolve to
+	AllowedHosts []string
+
+	// AllowedNetworks is a list of networks which may be connected to despite being internal, such as 10.1.2.0/24
+	AllowedNetworks []*net.IPNet
+
+	// Dialer is the underlying dialer. If nil, a dialer with a 30 second timeout is used.
+	Dialer *net.Dialer
+}
+
+// NewSafeDialer will create a new SafeDialer with the provided allowlist
+// Each item in the allowlist may be a CIDR (10.1.2.0/24), IP (10.1.2.3) or hostname (internal.example.com).
+func NewSafeDialer(allowlist ...string) (dialer *SafeDialer, parseErr error) {
+	dialer = &SafeDialer{}
+
+	for _, allowed := range allowlist { // For each allowlist item
+		if _, network, cidrErr := net.ParseCIDR(allowed); cidrErr == nil { // Is a CIDR
+			dialer.AllowedNetworks = append(dialer.AllowedNetworks, network)
+		} else if ip := net.ParseIP(allowed); ip != nil { // Is a single IP
+			bits := 8 * net.IPv4len
+
+			if ip.To4() == nil { // IPv6
+				bits = 8 * net.IPv6len
+			}
+
+			dialer.AllowedNetworks = append(dialer.AllowedNetworks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		} else if allowed != "" && !strings.ContainsAny(allowed, "/:") { // Is hopefully a hostname
+			dialer.AllowedHosts = append(dialer.AllowedHosts, strings.ToLower(allowed))
+		} else {
+			parseErr = fmt.Errorf("invalid allowlist entry %q", allowed)
+			return
+		}
+	}
+
+	return
+}
+
+// DialContext will connect to the provided address, refusing to connect if it resolves to an internal address
+// This may be used as the DialContext of an http.Transport.
+func (safeDialer *SafeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: 30 * time.Second}
+
+	if safeDialer.Dialer != nil { // Copy our provided dialer so we can set our Control function
+		dialer = *safeDialer.Dialer
+	}
+
+	host, _, splitErr := net.SplitHostPort(address)
+
+	if splitErr != nil {
+		return nil, splitErr
+	}
+
+	if !safeDialer.isAllowedHost(host) { // If this host is not explicitly allowed, check each IP we attempt to connect to
+		dialer.Control = func(network, resolvedAddress string, _ syscall.RawConn) error {
+			resolvedHost, _, _ := net.SplitHostPort(resolvedAddress)
+			ip := net.ParseIP(resolvedHost)
+
+			if !safeDialer.IsAllowed(ip) {
+				return &BlockedAddressError{Address: address, IP: ip}
+			}
+
+			return nil
+		}
+	}
+
+	return dialer.DialContext(ctx, network, address)
+}
+
+// IsAllowed will check if the provided IP may be connected to
+// IPs which are in one of our AllowedNetworks are always allowed. Otherwise private, loopback, link-local, multicast and unspecified IPs are refused.
+func (safeDialer *SafeDialer) IsAllowed(ip net.IP) bool {
+	if ip == nil { // Not a valid IP
+		return false
+	}
+
+	for _, network := range safeDialer.AllowedNetworks { // For each network in our allowlist
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return !isInternalIP(ip)
+}
+
+// Transport will create a new http.Transport using our DialContext
+// If base is an *http.Transport, it is cloned so its other settings, such as TLS configuration, are retained.
+// Proxies are not used, as the proxy would otherwise resolve and connect to the destination on our behalf.
+func (safeDialer *SafeDialer) Transport(base http.RoundTripper) (transport *http.Transport) {
+	if baseTransport, isTransport := base.(*http.Transport); isTransport && baseTransport != nil {
+		transport = baseTransport.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	transport.DialContext = safeDialer.DialContext
+	transport.DialTLSContext = nil // Ensure TLS connections also go through our DialContext
+	transport.Proxy = nil
+
+	return
+}
+
+// isAllowedHost will check if the provided hostname is in our AllowedHosts
+func (safeDialer *SafeDialer) isAllowedHost(host string) bool {
+	host = strings.ToLower(host)
+
+	for _, allowedHost := range safeDialer.AllowedHosts {
+		if host == allowedHost {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isInternalIP will check if the provided IP is private, loopback, link-local, multicast, unspecified or otherwise not publicly routable
+func isInternalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return true
+	}
+
+	if ip4 := ip.To4(); ip4 != nil { // IPv4 ranges not covered above
+		return ip4[0] == 0 || // 0.0.0.0/8 "this network"
+			ip4[0] == 10 || // 10.0.0.0/8 private
+			(ip4[0] == 172 && ip4[1]&0xf0 == 16) || // 172.16.0.0/12 private
+			(ip4[0] == 192 && ip4[1] == 168) || // 192.168.0.0/16 private
+			(ip4[0] == 100 && ip4[1]&0xc0 == 64) || // 100.64.0.0/10 carrier-grade NAT
+			(ip4[0] == 192 && ip4[1] == 0 && ip4[2] == 0) || // 192.0.0.0/24 IETF protocol assignments
+			(ip4[0] == 198 && ip4[1]&0xfe == 18) || // 198.18.0.0/15 benchmarking
+			ip4[0] >= 240 // 240.0.0.0/4 reserved, including broadcast
+	}
+
+	return ip[0]&0xfe == 0xfc // fc00::/7 unique local
+}
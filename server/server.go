@@ -0,0 +1,289 @@
+// Package server provides an HTTP API serving Sauron's link information, as used by the sauron-server command
+//
+// Endpoints:
+//
+//	GET  /v1/link?url=...   Get the link information of a single URL
+//	POST /v1/links          Get the link information of multiple URLs, provided as {"urls": [...]}
+//	GET  /healthz           Liveness, always 200 while the process is serving
+//	GET  /readyz            Readiness, 503 once the Handler is no longer ready, such as when shutting down
+//
+// If API keys are configured, /v1 endpoints require one via "Authorization: Bearer <key>" or "X-API-Key: <key>".
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/TryStreambits/sauron"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxBatchBodySize is the maximum size of a batch request body
+const maxBatchBodySize = 1 << 20 // 1 MiB
+
+// batchRequest is the body of a batch request
+type batchRequest struct {
+	URLs []string `json:"urls"`
+}
+
+// batchResponse is the response to a batch request, with a result for each URL in the order they were requested
+type batchResponse struct {
+	Results []linkResult `json:"results"`
+}
+
+// errorResponse is the body of an error response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// linkResult is the result of getting a single URL in a batch request
+type linkResult struct {
+	Error  string       `json:"error,omitempty"`
+	Link   *sauron.Link `json:"link,omitempty"`
+	Status int          `json:"status"`
+	URL    string       `json:"url"`
+}
+
+// Handler is an http.Handler serving link information from a Sauron Client
+// See sauron.Client.EnableSafeDialer before exposing it publicly.
+type Handler struct {
+	client *sauron.Client
+	config Config
+	mux    *http.ServeMux
+	ready  int32         // ready is 1 while we are accepting traffic
+	slots  chan struct{} // slots limits the number of links fetched at once
+}
+
+// Config is the configuration of a Handler
+type Config struct {
+	// APIKeys is the keys accepted for /v1 endpoints. If empty, no authentication is required.
+	APIKeys []string
+
+	// BatchTimeout is the maximum time for a batch request. Defaults to 60 seconds.
+	BatchTimeout time.Duration
+
+	// LinkTimeout is the maximum time for fetching a single link. Defaults to 15 seconds.
+	LinkTimeout time.Duration
+
+	// MaxBatch is the maximum number of URLs in a batch request
+	MaxBatch int
+
+	// MaxConcurrency is the maximum number of links fetched at once across all requests. Defaults to 1.
+	MaxConcurrency int
+
+	// MaxPerHost is the maximum number of links fetched at once from a single host within a batch request
+	MaxPerHost int
+}
+
+// NewHandler will create a new Handler using the provided Client and configuration
+// Any timeout or MaxConcurrency which is not set is given its default. The Handler is ready to receive traffic until SetReady(false) is called.
+func NewHandler(client *sauron.Client, config Config) (srv *Handler) {
+	if config.BatchTimeout <= 0 {
+		config.BatchTimeout = 60 * time.Second
+	}
+
+	if config.LinkTimeout <= 0 {
+		config.LinkTimeout = 15 * time.Second
+	}
+
+	if config.MaxConcurrency < 1 {
+		config.MaxConcurrency = 1
+	}
+
+	srv = &Handler{
+		client: client,
+		config: config,
+		mux:    http.NewServeMux(),
+		ready:  1,
+		slots:  make(chan struct{}, config.MaxConcurrency),
+	}
+
+	srv.mux.HandleFunc("/healthz", srv.handleHealth)
+	srv.mux.HandleFunc("/readyz", srv.handleReady)
+	srv.mux.Handle("/v1/link", srv.authenticate(http.HandlerFunc(srv.handleLink)))
+	srv.mux.Handle("/v1/links", srv.authenticate(http.HandlerFunc(srv.handleLinks)))
+
+	return
+}
+
+// ServeHTTP will serve the request using our routes
+func (srv *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mux.ServeHTTP(w, r)
+}
+
+// authenticate will wrap the provided handler, requiring one of our API keys if any are configured
+func (srv *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(srv.config.APIKeys) == 0 { // No authentication required
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		provided := r.Header.Get("X-API-Key")
+
+		if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") { // Prefer the Authorization header
+			provided = strings.TrimPrefix(authorization, "Bearer ")
+		}
+
+		for _, key := range srv.config.APIKeys { // Compare against every key in constant time
+			if provided != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sauron"`)
+		writeError(w, http.StatusUnauthorized, "Missing or invalid API key")
+	})
+}
+
+// getLink will get the link information for the provided URL, waiting for a free slot and limited to our link timeout
+func (srv *Handler) getLink(ctx context.Context, rawURL string) (*sauron.Link, error) {
+	select {
+	case srv.slots <- struct{}{}: // Acquired a slot
+		defer func() { <-srv.slots }()
+	case <-ctx.Done(): // Gave up waiting, such as the client disconnecting
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, srv.config.LinkTimeout)
+	defer cancel()
+
+	return srv.client.GetLinkContext(ctx, rawURL)
+}
+
+// handleHealth will respond to liveness checks
+func (srv *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleLink will respond with the link information of the url query parameter
+func (srv *Handler) handleLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+
+	rawURL := r.URL.Query().Get("url")
+
+	if rawURL == "" { // No URL to get
+		writeError(w, http.StatusBadRequest, "Missing url parameter")
+		return
+	}
+
+	link, linkErr := srv.getLink(r.Context(), rawURL)
+
+	if linkErr != nil {
+		writeError(w, StatusForError(linkErr), linkErr.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, link)
+}
+
+// handleLinks will respond with the link information of each URL in the batch, in the order they were provided
+func (srv *Handler) handleLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+
+	var batch batchRequest
+
+	if decodeErr := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(&batch); decodeErr != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+decodeErr.Error())
+		return
+	}
+
+	if len(batch.URLs) == 0 {
+		writeError(w, http.StatusBadRequest, "No urls provided")
+		return
+	}
+
+	if srv.config.MaxBatch > 0 && len(batch.URLs) > srv.config.MaxBatch {
+		writeError(w, http.StatusRequestEntityTooLarge, "Too many urls in batch")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), srv.config.BatchTimeout)
+	defer cancel()
+
+	batchResults := srv.client.GetLinks(ctx, batch.URLs, &sauron.BatchOptions{
+		Concurrency:        srv.config.MaxConcurrency,
+		Fetch:              srv.getLink, // Share our concurrency limit with all other requests
+		PerHostConcurrency: srv.config.MaxPerHost,
+	})
+
+	results := make([]linkResult, len(batchResults))
+
+	for index, batchResult := range batchResults {
+		results[index] = linkResult{Link: batchResult.Link, Status: http.StatusOK, URL: batchResult.URL}
+
+		if batchResult.Err != nil {
+			results[index].Error = batchResult.Err.Error()
+			results[index].Status = StatusForError(batchResult.Err)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, batchResponse{Results: results})
+}
+
+// handleReady will respond to readiness checks, failing once we are shutting down
+func (srv *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&srv.ready) != 1 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// SetReady will set whether we are accepting traffic, which is reported by /readyz
+// This should be set to false when shutting down, so load balancers stop sending new traffic.
+func (srv *Handler) SetReady(ready bool) {
+	var value int32
+
+	if ready {
+		value = 1
+	}
+
+	atomic.StoreInt32(&srv.ready, value)
+}
+
+// StatusForError will get the HTTP status a Handler responds with for an error from getting a link
+func StatusForError(err error) int {
+	var statusErr *sauron.HTTPStatusError
+
+	switch {
+	case errors.Is(err, context.Canceled): // Checked first, as a cancelled request is also a RequestError matching ErrNoResponse
+		return 499 // Client closed request
+	case errors.Is(err, sauron.ErrBlockedAddress):
+		return http.StatusForbidden
+	case errors.Is(err, sauron.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, sauron.ErrUnsupportedContent):
+		return http.StatusUnprocessableEntity
+	case errors.As(err, &statusErr), errors.Is(err, sauron.ErrNoResponse), errors.Is(err, sauron.ErrBodyTooLarge), errors.Is(err, sauron.ErrTooManyRedirects):
+		return http.StatusBadGateway
+	default: // Such as an invalid URL
+		return http.StatusBadRequest
+	}
+}
+
+// writeError will write an error response with the provided status and message
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+// writeJSON will write the provided value as a JSON response with the provided status
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
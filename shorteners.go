@@ -0,0 +1,119 @@
+package sauron
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// This file contains our URL shortener expansion
+
+// Expand will expand the provided URL using the DefaultClient, if it is from a URL shortener
+func Expand(ctx context.Context, rawURL string) (string, error) {
+	return DefaultClient.Expand(ctx, rawURL)
+}
+
+// Expand will expand the provided URL if it is from one of our Shorteners, returning the first URL it redirects to which is not from a shortener
+// URLs which are not from a shortener are returned as is. Any further redirects on the destination site are not followed.
+func (c *Client) Expand(ctx context.Context, rawURL string) (string, error) {
+	u, parseErr := url.Parse(rawURL)
+
+	if parseErr != nil {
+		return "", parseErr
+	}
+
+	destination, _, expandErr := c.expand(ctx, u)
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	return destination.String(), nil
+}
+
+// isShortener will check if the provided host is one of our Shorteners, or a subdomain of one
+func (c *Client) isShortener(host string) bool {
+	host = strings.ToLower(host)
+
+	for _, shortener := range c.Shorteners {
+		if hostMatches(host, shortener) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expand will follow the redirects of the provided URL while it is on one of our Shorteners, returning the first URL which is not
+// Any redirects after leaving our Shorteners, such as from http to https on the destination site, are left to the page fetch.
+// Each redirect is requested with HEAD, falling back to GET for shorteners which do not support HEAD.
+// If more than MaxShortenerHops redirects are followed, ErrTooManyRedirects is returned.
+func (c *Client) expand(ctx context.Context, u *url.URL) (destination *url.URL, redirects []Redirect, expandErr error) {
+	destination = u
+
+	client := *c.HTTPClient // Copy our client so we can handle redirects ourselves
+
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for c.isShortener(destination.Hostname()) { // While we are still on a shortener, such as t.co redirecting to bit.ly
+		statusCode, location, requestErr := c.requestRedirect(ctx, &client, destination)
+
+		if requestErr != nil {
+			expandErr = requestErr
+			return
+		}
+
+		if location == "" { // Shortener did not redirect, so it is our destination
+			return
+		}
+
+		if len(redirects) == c.MaxShortenerHops { // Followed too many redirects
+			expandErr = ErrTooManyRedirects
+			return
+		}
+
+		next, parseErr := destination.Parse(location) // Resolve the location against our current URL
+
+		if parseErr != nil { // Invalid location, so treat our current URL as the destination
+			return
+		}
+
+		redirects = append(redirects, Redirect{StatusCode: statusCode, URL: destination.String()})
+		destination = next
+	}
+
+	return
+}
+
+// requestRedirect will request the provided URL without following redirects, returning the status code and location of any redirect
+// HEAD is used where possible, falling back to GET if the request fails or the shortener returns an error status.
+func (c *Client) requestRedirect(ctx context.Context, client *http.Client, u *url.URL) (statusCode int, location string, requestErr error) {
+	request := c.NewRequest(ctx, u)
+	request.Method = http.MethodHead
+	response, getErr := client.Do(request)
+
+	if getErr != nil || response.StatusCode >= 400 { // Shortener may not support HEAD, so try GET
+		if getErr == nil {
+			response.Body.Close()
+		}
+
+		response, getErr = client.Do(c.NewRequest(ctx, u))
+	}
+
+	if getErr != nil { // Failed to get a response
+		requestErr = &RequestError{Err: getErr, URL: u.String()}
+		return
+	}
+
+	response.Body.Close() // We only need the headers
+	statusCode = response.StatusCode
+
+	if statusCode >= 300 && statusCode < 400 { // Is a redirect
+		location = response.Header.Get("Location")
+	}
+
+	return
+}
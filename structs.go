@@ -1,6 +1,7 @@
 package sauron
 
 import (
+	"context"
 	"github.com/PuerkitoBio/goquery"
 	"net/url"
 )
@@ -10,11 +11,119 @@ import (
 // LinkParser is a function which takes in a parsed document, URL struct and a string, and returns a pointer to a Link or an error
 type LinkParser func(*goquery.Document, *url.URL, string) (*Link, error)
 
+// ContextLinkParser is a LinkParser which additionally takes in the context of the request
+// Parsers which make secondary requests should use this context so they are cancelled alongside the page fetch
+type ContextLinkParser func(context.Context, *goquery.Document, *url.URL, string) (*Link, error)
+
+// ParserOption is an option which may be provided when registering a parser
+type ParserOption int
+
+const (
+	// HeadOnly indicates the parser only requires the document head, such as meta and link elements.
+	// Pages handled by the parser will stop being read once </head> is reached, saving bandwidth on large pages.
+	HeadOnly ParserOption = iota
+)
+
+// WithContext will wrap the provided LinkParser as a ContextLinkParser which ignores the context
+func WithContext(parser LinkParser) ContextLinkParser {
+	return func(_ context.Context, doc *goquery.Document, u *url.URL, fullURL string) (*Link, error) {
+		return parser(doc, u, fullURL)
+	}
+}
+
+// Entity is the primary entity described by the structured data of a page, such as an Article, Product or VideoObject
+// This is from the JSON-LD of the page, or its microdata or RDFa if it has no JSON-LD.
+type Entity struct {
+	// Type is the schema.org type of the entity, such as NewsArticle or Product
+	Type string
+
+	Author, DatePublished, Description, Headline, Image string
+
+	// Duration is the ISO 8601 duration of the entity, such as PT4M13S for a VideoObject
+	Duration string
+
+	// Currency and Price are from the offers of the entity, such as a Product
+	Currency, Price string
+
+	// Rating and RatingCount are from the aggregateRating of the entity
+	Rating, RatingCount string
+
+	// Properties is the full structured data of the entity in JSON-LD form, for any properties not covered above
+	Properties map[string]interface{}
+}
+
+// Redirect is a redirect followed while fetching a page
+type Redirect struct {
+	// StatusCode is the redirect status returned, such as 301 or 302
+	StatusCode int
+
+	// URL is the URL which returned the redirect
+	URL string
+}
+
 // Link is our structured information about a URL provided to Sauron's Parser
 type Link struct {
 	Description, Favicon, Host, Image, Title, URI string
 
+	// CanonicalURL is the canonical URL the page declares via <link rel="canonical"> or og:url, if any
+	CanonicalURL string
+
+	// FinalURL is the URL of the page after any rewriting and redirects
+	FinalURL string
+
+	// OEmbed is the oEmbed response for the page, if the Client has FetchOEmbed enabled and the page has a provider
+	OEmbed *OEmbed
+
+	// Redirects is the redirects followed while fetching the page, in the order they were followed
+	Redirects []Redirect
+
+	// Entity is the primary entity from the structured data of the page, if any
+	Entity *Entity
+
+	// Icons is the icons of the page, ranked from most to least preferred. Favicon is the URL of the first icon.
+	Icons []Icon
+
+	// Items is the top-level microdata and RDFa items of the page, if any
+	Items []*Item
+
 	// Extras is our extra metadata.
 	// This may be used by internal and external parsers to communicate additional information about the URL in question
 	Extras map[string]string
 }
+
+// Copy will create a copy of the link, with its own Extras, Icons and Redirects
+// Entity, Items and OEmbed are shared with the original, and should be treated as read-only. Copying a nil link returns nil.
+func (link *Link) Copy() *Link {
+	if link == nil {
+		return nil
+	}
+
+	copied := *link
+
+	if link.Extras != nil {
+		copied.Extras = make(map[string]string, len(link.Extras))
+
+		for extrasType, value := range link.Extras {
+			copied.Extras[extrasType] = value
+		}
+	}
+
+	copied.Icons = append([]Icon(nil), link.Icons...)
+	copied.Redirects = append([]Redirect(nil), link.Redirects...)
+
+	return &copied
+}
+
+// Key will get the URL which identifies the page, so different URLs for the same page may be collapsed
+// This is the canonical URL if the page declares one, otherwise the final URL, otherwise the provided URI.
+func (link *Link) Key() string {
+	if link.CanonicalURL != "" {
+		return link.CanonicalURL
+	}
+
+	if link.FinalURL != "" {
+		return link.FinalURL
+	}
+
+	return link.URI
+}
@@ -1,15 +1,77 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/JoshStrobl/trunk"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/TryStreambits/sauron"
+	"github.com/TryStreambits/sauron/oembed"
+	"github.com/TryStreambits/sauron/server"
+	"golang.org/x/text/encoding/japanese"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// failures is the number of checks which have failed, so we can exit with a non-zero status
+var failures int32
+
 func main() {
+	localOnly := flag.Bool("local", false, "Only run the tests against local servers, which do not require network access")
+	flag.Parse()
+
+	ConcurrentRegistryTest() // Run with go run -race to detect any races in the registry
+	RegistryPrecedenceTest()
+	RewriterOverrideTest()
+	TransportTest()
+	ParserContextTest()
+	ShortenerTest()
+	SafeDialerTest()
+	HeadOnlyBodySizeTest()
+	CharsetTest()
+	OpenGraphTest()
+	JSONLDTest()
+	MicrodataTest()
+	ResolveURLTest()
+	NormalizeTest()
+	OEmbedConsumerTest()
+	OEmbedHandlerTest()
+	CoalesceTest()
+	BatchTest()
+	CacheTest()
+	ServerTest()
+	CLITest()
+
+	if !*localOnly { // If we should also run our tests against live sites
+		NetworkTest()
+	}
+
+	if failed := atomic.LoadInt32(&failures); failed != 0 { // If any of our checks failed
+		trunk.LogErr(fmt.Sprintf("%d checks failed", failed))
+		os.Exit(1)
+	}
+}
+
+// logErr will log the provided failure and record it, so we exit with a non-zero status
+func logErr(message string) {
+	atomic.AddInt32(&failures, 1)
+	trunk.LogErr(message)
+}
+
+// NetworkTest will fetch links from live sites, which requires network access
+func NetworkTest() {
 	image, imageLinkErr := sauron.GetLink("https://i3.ytimg.com/vi/OE-Y-PotqTQ/maxresdefault.jpg")
 
 	if imageLinkErr == nil { // Got the Image
@@ -17,10 +79,10 @@ func main() {
 			trunk.LogSuccess("Got JPG")
 			fmt.Printf("%v\n", image)
 		} else {
-			trunk.LogErr("Failed to determine that our image is precisely that.")
+			logErr("Failed to determine that our image is precisely that.")
 		}
 	} else {
-		trunk.LogErr(fmt.Sprintf("Failed to get Image: %v", imageLinkErr))
+		logErr(fmt.Sprintf("Failed to get Image: %v", imageLinkErr))
 	}
 
 	video, videoLinkErr := sauron.GetLink("http://mirrors.standaloneinstaller.com/video-sample/small.mp4")
@@ -30,10 +92,10 @@ func main() {
 			trunk.LogSuccess("Got MP4")
 			fmt.Printf("%v\n", video)
 		} else {
-			trunk.LogErr("Failed to determine that our video is prcisely that.")
+			logErr("Failed to determine that our video is prcisely that.")
 		}
 	} else {
-		trunk.LogErr(fmt.Sprintf("Failed to get Video: %v", videoLinkErr))
+		logErr(fmt.Sprintf("Failed to get Video: %v", videoLinkErr))
 	}
 
 	twitter, twitterLinkErr := sauron.GetLink("https://twitter.com/trystreambits/status/1246090584714027010")
@@ -42,7 +104,7 @@ func main() {
 		trunk.LogSuccess("Got @trystreambits Tweet")
 		fmt.Printf("%v\n", twitter)
 	} else {
-		trunk.LogErr(fmt.Sprintf("Failed to get Tweet: %v", twitterLinkErr))
+		logErr(fmt.Sprintf("Failed to get Tweet: %v", twitterLinkErr))
 	}
 
 	twitchStreamer, twitchStreamerLinkErr := sauron.GetLink("https://www.twitch.tv/towelliee")
@@ -52,12 +114,12 @@ func main() {
 			twitchStreamer.Extras["Game"] == "" || // Game is empty
 			!strings.HasPrefix(twitchStreamer.Extras["GameLink"], "https://www.twitch.tv/directory/game/") || // Not expected beginning of URL for game directory listing
 			!strings.HasPrefix(twitchStreamer.Extras["GameArtFull"], "https://static-cdn.jtvnw.net/ttv-boxart/") { // Not expected beginning of URL for box art
-			trunk.LogErr(fmt.Sprintf("Fetched Streamer details but does not match expectation: %s", twitchStreamer))
+			logErr(fmt.Sprintf("Fetched Streamer details but does not match expectation: %v", twitchStreamer))
 		} else {
 			trunk.LogSuccess(fmt.Sprintf("Got Twitch streamer details: %v", twitchStreamer))
 		}
 	} else {
-		trunk.LogErr(fmt.Sprintf("Failed to get Twitch streamer: %v", twitchStreamerLinkErr))
+		logErr(fmt.Sprintf("Failed to get Twitch streamer: %v", twitchStreamerLinkErr))
 	}
 
 	twitchClip, twitchClipLinkErr := sauron.GetLink("https://www.twitch.tv/towelliee/clip/VastTentativeDinosaurGOWSkull")
@@ -69,36 +131,36 @@ func main() {
 			twitchClip.Extras["Game"] != "World of Warcraft" || // Game doesn't match expectation
 			twitchClip.Extras["GameLink"] != "https://www.twitch.tv/directory/game/World of Warcraft" || // Not expected URL for game
 			twitchClip.Extras["GameArtFull"] != "https://static-cdn.jtvnw.net/ttv-boxart/World%20of%20Warcraft.jpg" { // Full game art doesn't match
-			trunk.LogErr(fmt.Sprintf("Fetched Clip details but does not match expectation: %v", twitchClip))
+			logErr(fmt.Sprintf("Fetched Clip details but does not match expectation: %v", twitchClip))
 		} else {
 			trunk.LogSuccess(fmt.Sprintf("Got Twitch clip details: %v", twitchClip))
 		}
 	} else { // Failed to get the clip
-		trunk.LogErr(fmt.Sprintf("Failed to get the Twitch clip: %v", twitchClipLinkErr))
+		logErr(fmt.Sprintf("Failed to get the Twitch clip: %v", twitchClipLinkErr))
 	}
 
 	twitchSecondaryClip, twitchSecondaryClipLinkErr := sauron.GetLink("https://clips.twitch.tv/VastTentativeDinosaurGOWSkull")
 
 	if twitchSecondaryClipLinkErr == nil { // Got the clip
 		if twitchSecondaryClip.Title != twitchClip.Title { // If our Title doesn't match our proper full Twitch clip URL
-			trunk.LogErr(fmt.Sprintf("Fetched Clip details but does not match expectation: %v", twitchSecondaryClip))
+			logErr(fmt.Sprintf("Fetched Clip details but does not match expectation: %v", twitchSecondaryClip))
 		} else {
 			trunk.LogSuccess(fmt.Sprintf("Got Twitch clip details for clips.twitch.tv subdomain: %v", twitchSecondaryClip))
 		}
 	} else {
-		trunk.LogErr(fmt.Sprintf("Failed to get the Twitch clip via clips.twitch.tv: %v", twitchSecondaryClipLinkErr))
+		logErr(fmt.Sprintf("Failed to get the Twitch clip via clips.twitch.tv: %v", twitchSecondaryClipLinkErr))
 	}
 
 	bigBuckBunnyLink, linkErr := sauron.GetLink("https://www.youtube.com/watch?v=YE7VzlLtp-4")
 
 	if linkErr == nil { // Successfully got link data
 		if bigBuckBunnyLink.Title == "Big Buck Bunny" && bigBuckBunnyLink.Extras["IsVideo"] == "true" { // Successfully fetched
-			trunk.LogSuccess(fmt.Sprintf("Fetched Big Buck Bunny. Has the following content: %s", bigBuckBunnyLink))
+			trunk.LogSuccess(fmt.Sprintf("Fetched Big Buck Bunny. Has the following content: %v", bigBuckBunnyLink))
 		} else { // Details do not match
-			trunk.LogErr(fmt.Sprintf("Successfully fetched Big Buck Bunny but content does not match expectation: %s", bigBuckBunnyLink))
+			logErr(fmt.Sprintf("Successfully fetched Big Buck Bunny but content does not match expectation: %v", bigBuckBunnyLink))
 		}
 	} else { // If we failed to fetch Big Buck Bunny
-		trunk.LogErr(fmt.Sprintf("Failed to get Big Buck Bunny: %v", linkErr))
+		logErr(fmt.Sprintf("Failed to get Big Buck Bunny: %v", linkErr))
 	}
 
 	playlistTestLink, playlistTestLinkErr := sauron.GetLink("https://www.youtube.com/playlist?list=PLFF5D72E24079FB50")
@@ -107,30 +169,30 @@ func main() {
 		if playlistTestLink.Title == "Mat Kearney - Young Love" && // Name matches
 			playlistTestLink.Extras["IsPlaylist"] == "true" && // Is a Playlist
 			playlistTestLink.Image == "https://i.ytimg.com/vi/FANROVxej50/hqdefault.jpg" { // Playlist Image matches
-			trunk.LogSuccess(fmt.Sprintf("Fetched Youtube Playlist. Has the following content: %s\n", playlistTestLink))
+			trunk.LogSuccess(fmt.Sprintf("Fetched Youtube Playlist. Has the following content: %v\n", playlistTestLink))
 		} else {
-			trunk.LogErr(fmt.Sprintf("Successfully fetched Youtube Playlist but content does not match expectation: %s\n", playlistTestLink))
+			logErr(fmt.Sprintf("Successfully fetched Youtube Playlist but content does not match expectation: %v\n", playlistTestLink))
 		}
 	} else {
-		trunk.LogErr(fmt.Sprintf("Failed to get Youtube Playlist: %v", playlistTestLink))
+		logErr(fmt.Sprintf("Failed to get Youtube Playlist: %v", playlistTestLink))
 	}
 
 	redditPost, redditLinkErr := sauron.GetLink("https://www.reddit.com/r/SolusProject/comments/b2a8x0/solus_4_fortitude_released_solus/")
 
 	if redditLinkErr == nil { // Successfully got reddit post
-		if redditPost.Title == "Solus 4 Fortitude Released | Solus : SolusProject" && redditPost.Extras["Likes"] != "" { // Successfully got Reddit post
-			trunk.LogSuccess(fmt.Sprintf("Fetched Reddit post. Has the following content: %s\n", redditPost))
+		if redditPost.Title == "Solus 4 Fortitude Released | Solus" && redditPost.Extras["Likes"] != "" { // Successfully got Reddit post, titled by og:title rather than <title>
+			trunk.LogSuccess(fmt.Sprintf("Fetched Reddit post. Has the following content: %v\n", redditPost))
 		} else { // Failed to get reddit post, potentially likes
-			trunk.LogErr(fmt.Sprintf("Successfully fetched Reddit post but content does not match expectations: %s\n", redditPost))
+			logErr(fmt.Sprintf("Successfully fetched Reddit post but content does not match expectations: %v\n", redditPost))
 		}
 	} else { // Failed to fetch Reddit post
-		trunk.LogErr(fmt.Sprintf("Failed to get Reddit post: %v", redditLinkErr))
+		logErr(fmt.Sprintf("Failed to get Reddit post: %v", redditLinkErr))
 	}
 
 	downvotedPost, redditDownvoteLinkErr := sauron.GetLink("https://old.reddit.com/r/linux/comments/ielvry/linux_used_to_be_to_bring_life_to_your_old/")
 
 	if redditDownvoteLinkErr == nil { // Successfully got the downvoted reddit post
-		trunk.LogSuccess(fmt.Sprintf("Fetched downvoted Reddit post. Has the following content: %s\n", downvotedPost))
+		trunk.LogSuccess(fmt.Sprintf("Fetched downvoted Reddit post. Has the following content: %v\n", downvotedPost))
 	}
 	sauron.Register("joshuastrobl.com", PersonalSiteHandler)
 
@@ -138,24 +200,24 @@ func main() {
 
 	if personalLinkErr == nil { // Successfully got personal site
 		if personalSiteLink.Title == "Home | Joshua Strobl" && strings.HasPrefix(personalSiteLink.Extras["Generator"], "Hugo") { // Successfully got Personal Site
-			trunk.LogSuccess(fmt.Sprintf("Fetched Personal Site. Has the following content: %s\n", personalSiteLink))
+			trunk.LogSuccess(fmt.Sprintf("Fetched Personal Site. Has the following content: %v\n", personalSiteLink))
 		} else { // Failed to get personal site, potentially generator info
-			trunk.LogErr(fmt.Sprintf("Successfully fetched Personal Site but content does not match expecations: %s\n", personalSiteLink))
+			logErr(fmt.Sprintf("Successfully fetched Personal Site but content does not match expecations: %v\n", personalSiteLink))
 		}
 	} else { // Failed to get personal site
-		trunk.LogErr(fmt.Sprintf("Failed to get Personal Site: %v", personalLinkErr))
+		logErr(fmt.Sprintf("Failed to get Personal Site: %v", personalLinkErr))
 	}
 
 	gogLink, gogLinkErr := sauron.GetLink("https://www.gog.com/game/the_witcher")
 
 	if gogLinkErr == nil { // Got GOG
 		if strings.HasSuffix(gogLink.Title, "The Witcher: Enhanced Edition on GOG.com") { // If we successfully fetched the title when they reuse it weirdly
-			trunk.LogSuccess(fmt.Sprintf("Fetched GOG site. Has the following content: %s\n", gogLink))
+			trunk.LogSuccess(fmt.Sprintf("Fetched GOG site. Has the following content: %v\n", gogLink))
 		} else { // Failed to get the correct title
-			trunk.LogErr(fmt.Sprintf("Failed to fetch the GOG site which has weird title re-use: %s\n", gogLink))
+			logErr(fmt.Sprintf("Failed to fetch the GOG site which has weird title re-use: %v\n", gogLink))
 		}
 	} else {
-		trunk.LogErr(fmt.Sprintf("Failed to get GOG: %v", personalLinkErr))
+		logErr(fmt.Sprintf("Failed to get GOG: %v", personalLinkErr))
 	}
 }
 
@@ -173,3 +235,1295 @@ func PersonalSiteHandler(doc *goquery.Document, u *url.URL, fullPath string) (li
 
 	return
 }
+
+// ConcurrentRegistryTest will register and unregister parsers while fetching links against a local server
+func ConcurrentRegistryTest() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Sauron</title></head></html>"))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	serverURL, _ := url.Parse(server.URL)
+
+	var failures int
+	var failuresMutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < 25; i++ {
+		wg.Add(2)
+
+		go func(index int) { // Register, force register and unregister parsers
+			defer wg.Done()
+			client.ForceRegister(serverURL.Host, client.Primitive)
+			client.Register(fmt.Sprintf("%d.example.com", index), client.Primitive)
+			client.Unregister(serverURL.Host)
+			client.HasOverridden("reddit.com")
+		}(i)
+
+		go func() { // Fetch our link while parsers are being registered
+			defer wg.Done()
+
+			if link, linkErr := client.GetLink(server.URL); linkErr != nil || link.Title != "Sauron" { // Failed to get our link
+				failuresMutex.Lock()
+				failures++
+				failuresMutex.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if failures == 0 {
+		trunk.LogSuccess("Fetched links while concurrently registering parsers")
+	} else {
+		logErr(fmt.Sprintf("Failed to fetch %d links while concurrently registering parsers", failures))
+	}
+}
+
+// RegistryPrecedenceTest will ensure exact, wildcard and regex hostnames are matched in order of precedence
+func RegistryPrecedenceTest() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Sauron</title></head></html>"))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	client.SetTransport(TestTransport{Server: server})
+
+	for _, hostName := range []string{"example.com", "*.example.com", "*.eu.example.com", `^shop\.`, `^shop\.example\.org$`, `example\.net$`, `^\D+\.example\.dev$`, `^Media\.\S+\.biz$`} { // Register a parser which identifies its hostname
+		registeredName := hostName
+
+		client.Register(hostName, func(doc *goquery.Document, u *url.URL, fullPath string) (*sauron.Link, error) {
+			return &sauron.Link{Title: registeredName}, nil
+		})
+	}
+
+	expectations := map[string]string{
+		"example.com":          "example.com",
+		"example.com:8080":     "example.com",
+		"www.example.com":      "*.example.com",
+		"www.example.com:8080": "*.example.com",
+		"cdn.eu.example.com":   "*.eu.example.com",
+		"shop.example.com":     "*.example.com",
+		"shop.example.org":     `^shop\.example\.org$`,
+		"shop.example.io":      `^shop\.`,
+		"shop.example.net":     `example\.net$`, // Longest regex match wins
+		"www.example.net:8080": `example\.net$`,
+		"static.example.dev":   `^\D+\.example\.dev$`, // Escapes such as \D must not be lowercased to \d
+		"media.example.biz":    `^Media\.\S+\.biz$`,   // Regular expressions match case-insensitively
+	}
+
+	for host, expectedParser := range expectations {
+		link, linkErr := client.GetLink("http://" + host + "/")
+
+		if linkErr == nil && link.Title == expectedParser {
+			trunk.LogSuccess(fmt.Sprintf("Used %s parser for %s", expectedParser, host))
+		} else if linkErr == nil {
+			logErr(fmt.Sprintf("Used %s parser for %s rather than %s", link.Title, host, expectedParser))
+		} else {
+			logErr(fmt.Sprintf("Failed to get %s: %v", host, linkErr))
+		}
+	}
+}
+
+// RewriterOverrideTest will ensure overriding our internal Reddit parser also stops our internal Reddit rewriter from applying
+func RewriterOverrideTest() {
+	var requestedHost string
+	var requestedHostMutex sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedHostMutex.Lock()
+		requestedHost = r.Host
+		requestedHostMutex.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Reddit</title></head></html>"))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	client.SetTransport(TestTransport{Server: server})
+
+	if link, linkErr := client.GetLink("https://reddit.com/r/golang"); linkErr == nil && requestedHost == "old.reddit.com" && link.Extras["IsRedditLink"] == "true" {
+		trunk.LogSuccess("Used internal Reddit rewriter and parser")
+	} else {
+		logErr(fmt.Sprintf("Did not use internal Reddit rewriter and parser: %v %v (requested %s)", link, linkErr, requestedHost))
+	}
+
+	client.ForceRegister("reddit.com", func(doc *goquery.Document, u *url.URL, fullPath string) (*sauron.Link, error) {
+		return &sauron.Link{Host: u.Host, Title: "Custom"}, nil
+	})
+
+	link, linkErr := client.GetLink("https://reddit.com/r/golang")
+
+	if linkErr == nil && client.HasOverridden("reddit.com") && requestedHost == "reddit.com" && link.Title == "Custom" {
+		trunk.LogSuccess("Used overridden Reddit parser without internal rewriter")
+	} else {
+		logErr(fmt.Sprintf("Did not use overridden Reddit parser: %v %v (requested %s)", link, linkErr, requestedHost))
+	}
+}
+
+// TestTransport is a RoundTripper which sends every request to our local server, regardless of host
+type TestTransport struct {
+	Server *httptest.Server
+}
+
+// RoundTrip will send the request to our local server
+func (transport TestTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	serverURL, _ := url.Parse(transport.Server.URL)
+	localRequest := request.Clone(request.Context())
+	localRequest.URL.Scheme = serverURL.Scheme
+	localRequest.URL.Host = serverURL.Host
+	localRequest.Host = request.URL.Host // Retain the original host so our server knows what was requested
+
+	response, roundTripErr := http.DefaultTransport.RoundTrip(localRequest)
+
+	if response != nil { // Report the original request, so final URLs keep the requested host
+		response.Request = request
+	}
+
+	return response, roundTripErr
+}
+
+// TransportTest will fetch a Twitch clip through a custom transport against a local server, including the secondary GQL request
+func TransportTest() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "gql.twitch.tv" { // GQL request made by our Twitch parser
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[{"data":{"clip":{"broadcaster":{"displayName":"Towelliee"},"game":{"name":"World of Warcraft"},"slug":"VastTentativeDinosaurGOWSkull","title":"eclipse"}}}]`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Twitch</title></head></html>"))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	client.SetTransport(TestTransport{Server: server})
+
+	clip, clipErr := client.GetLink("https://clips.twitch.tv/VastTentativeDinosaurGOWSkull")
+
+	if clipErr == nil { // Got the clip from our local server
+		if clip.Title == "Towelliee - eclipse - Twitch" && clip.Extras["Game"] == "World of Warcraft" {
+			trunk.LogSuccess("Fetched Twitch clip through custom transport")
+		} else {
+			logErr(fmt.Sprintf("Fetched Twitch clip through custom transport but does not match expectation: %v", clip))
+		}
+	} else {
+		logErr(fmt.Sprintf("Failed to get Twitch clip through custom transport: %v", clipErr))
+	}
+}
+
+// ParserContextTest will ensure the secondary requests of our internal parsers, such as for a web app manifest, are cancelled with the context
+func ParserContextTest() {
+	manifestCancelled := make(chan bool, 1) // manifestCancelled is if each manifest request was cancelled, rather than timing out
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/manifest.json" { // Manifest which never responds, until the request is cancelled
+			select {
+			case <-r.Context().Done():
+				manifestCancelled <- true
+			case <-time.After(3 * time.Second):
+				manifestCancelled <- false
+			}
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Video - YouTube</title><link rel="manifest" href="/manifest.json"></head></html>`))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	client.FetchManifestIcons = true
+	client.SetTransport(TestTransport{Server: server})
+
+	for _, pageURL := range []string{"https://www.youtube.com/watch?v=YE7VzlLtp-4", "https://www.reddit.com/r/golang"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+		client.GetLinkContext(ctx, pageURL)
+		cancel()
+
+		if <-manifestCancelled {
+			trunk.LogSuccess(fmt.Sprintf("Cancelled manifest request of %s with the context", pageURL))
+		} else {
+			logErr(fmt.Sprintf("Did not cancel manifest request of %s with the context", pageURL))
+		}
+	}
+}
+
+// ShortenerTest will ensure shortened URLs are expanded until they leave our shorteners, leaving the destination site to the page fetch
+func ShortenerTest() {
+	var requests []string
+	var requestsMutex sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsMutex.Lock()
+		requests = append(requests, r.Method+" "+r.Host)
+		requestsMutex.Unlock()
+
+		switch r.Host {
+		case "bit.ly": // Shortener redirecting to another shortener
+			http.Redirect(w, r, "https://t.co/sauron", http.StatusMovedPermanently)
+		case "t.co":
+			http.Redirect(w, r, "https://example.com/page", http.StatusFound)
+		case "example.com": // Destination site redirecting to its www subdomain
+			http.Redirect(w, r, "https://www.example.com/page", http.StatusMovedPermanently)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><head><title>Sauron</title></head></html>"))
+		}
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	client.ExpandShorteners = true
+	client.SetTransport(TestTransport{Server: server})
+
+	link, linkErr := client.GetLink("https://bit.ly/sauron")
+	expectedRequests := "HEAD bit.ly, HEAD t.co, GET example.com, GET www.example.com"
+
+	if linkErr == nil && len(link.Redirects) == 3 && strings.Join(requests, ", ") == expectedRequests {
+		trunk.LogSuccess("Expanded shortened URL without requesting the destination site twice")
+	} else {
+		logErr(fmt.Sprintf("Did not expand shortened URL as expected: %v %v (requests %s)", link, linkErr, strings.Join(requests, ", ")))
+	}
+}
+
+// SafeDialerTest will ensure a Client with the safe dialer enabled refuses to connect to our local server unless allowlisted
+func SafeDialerTest() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Sauron</title></head></html>"))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	client.EnableSafeDialer()
+
+	if _, blockedErr := client.GetLink(server.URL); errors.Is(blockedErr, sauron.ErrBlockedAddress) { // Loopback was refused
+		trunk.LogSuccess("Refused to connect to loopback address with safe dialer")
+	} else {
+		logErr(fmt.Sprintf("Safe dialer did not refuse loopback address: %v", blockedErr))
+	}
+
+	allowedClient := sauron.NewClient()
+	allowedClient.EnableSafeDialer("127.0.0.0/8")
+
+	if _, allowedErr := allowedClient.GetLink(server.URL); allowedErr == nil { // Allowlisted network was permitted
+		trunk.LogSuccess("Connected to allowlisted loopback address with safe dialer")
+	} else {
+		logErr(fmt.Sprintf("Safe dialer refused allowlisted address: %v", allowedErr))
+	}
+}
+
+// CharsetTest will ensure pages are decoded to UTF-8 using their declared or sniffed character set
+func CharsetTest() {
+	shiftJISTitle, _ := japanese.ShiftJIS.NewEncoder().String("日本語のページ")
+
+	pages := map[string]struct {
+		Content       string
+		ContentType   string
+		ExpectedTitle string
+	}{
+		"/shift-jis": {Content: "<html><head><title>" + shiftJISTitle + "</title></head></html>", ContentType: "text/html; charset=Shift_JIS", ExpectedTitle: "日本語のページ"},
+		"/meta":      {Content: "<html><head><meta charset=\"windows-1252\"><title>Caf\xe9</title></head></html>", ContentType: "text/html", ExpectedTitle: "Café"},
+		"/bom":       {Content: "\xef\xbb\xbf<html><head><title>Na\xc3\xafve</title></head></html>", ContentType: "text/html; charset=windows-1252", ExpectedTitle: "Naïve"}, // Byte order mark takes precedence over the Content-Type
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := pages[r.URL.Path]
+		w.Header().Set("Content-Type", page.ContentType)
+		w.Write([]byte(page.Content))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+
+	for path, page := range pages {
+		link, linkErr := client.GetLink(server.URL + path)
+
+		if linkErr == nil && link.Title == page.ExpectedTitle {
+			trunk.LogSuccess(fmt.Sprintf("Decoded %s page to UTF-8", path))
+		} else {
+			logErr(fmt.Sprintf("Failed to decode %s page to UTF-8: %v %v", path, link, linkErr))
+		}
+	}
+}
+
+// OpenGraphTest will ensure Open Graph and Twitter Card meta are read from both property and name attributes, preferring Open Graph
+func OpenGraphTest() {
+	pages := map[string]struct {
+		Content  string
+		Expected map[string]string
+	}{
+		"/both": {
+			Content: `<html><head>
+				<meta name="twitter:title" content="Twitter Title"><meta property="og:title" content="Open Graph Title">
+				<meta property="twitter:description" content="Twitter Description"><meta name="og:description" content="Open Graph Description"><meta name="description" content="Description">
+				<meta property="twitter:image" content="/twitter.png"><meta name="og:image" content="/og.png"><meta property="og:image:width" content="1200">
+				<meta name="twitter:image:alt" content="Twitter Alt"><meta property="og:image:alt" content="Open Graph Alt">
+				<meta property="og:video" content="/video.webm"><meta property="og:video:secure_url" content="/video.mp4">
+				<meta name="twitter:player" content="/player"><meta name="og:site_name" content="Sauron">
+			</head></html>`,
+			Expected: map[string]string{
+				"Title":         "Open Graph Title",
+				"Description":   "Open Graph Description",
+				"Image":         "/og.png",
+				"ImageAlt":      "Open Graph Alt",
+				"ImageWidth":    "1200",
+				"SiteName":      "Sauron",
+				"TwitterPlayer": "/player",
+				"Video":         "/video.mp4", // og:video:secure_url takes precedence over og:video
+			},
+		},
+		"/twitter": {
+			Content: `<html><head><title>Title</title>
+				<meta property="twitter:title" content="Twitter Title"><meta name="twitter:description" content="Twitter Description"><meta name="description" content="Description">
+				<meta property="twitter:image" content="/twitter.png"><meta property="twitter:image:alt" content="Twitter Alt">
+			</head></html>`,
+			Expected: map[string]string{
+				"Title":       "Twitter Title",
+				"Description": "Twitter Description",
+				"Image":       "/twitter.png",
+				"ImageAlt":    "Twitter Alt",
+			},
+		},
+		"/plain": {
+			Content: `<html><head><title>Title</title><meta name="description" content="Description"></head><body><img src="/body.png"></body></html>`,
+			Expected: map[string]string{
+				"Title":       "Title",
+				"Description": "Description",
+				"Image":       "/body.png",
+			},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(pages[r.URL.Path].Content))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+
+	for path, page := range pages {
+		link, linkErr := client.GetLink(server.URL + path)
+
+		if linkErr != nil {
+			logErr(fmt.Sprintf("Failed to get %s page: %v", path, linkErr))
+			continue
+		}
+
+		fields := map[string]string{"Title": link.Title, "Description": link.Description, "Image": link.Image}
+
+		for extrasType, content := range link.Extras {
+			fields[extrasType] = content
+		}
+
+		for field, expected := range page.Expected {
+			if strings.HasPrefix(expected, "/") { // Relative URLs are resolved against our server
+				expected = server.URL + expected
+			}
+
+			if fields[field] == expected {
+				trunk.LogSuccess(fmt.Sprintf("Got %s of %s page", field, path))
+			} else {
+				logErr(fmt.Sprintf("Got %s of %s page as %q rather than %q", field, path, fields[field], expected))
+			}
+		}
+	}
+}
+
+// JSONLDTest will ensure the primary entity is found in the JSON-LD of a page, including @graph, mainEntity and @id references, offers and ratings
+func JSONLDTest() {
+	pages := map[string]struct {
+		Content  string
+		Expected map[string]string
+	}{
+		"/graph": {
+			Content: `<html><head><title>Middle-earth News</title><script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+				{"@type": "WebSite", "@id": "#website", "name": "Middle-earth News"},
+				{"@type": "WebPage", "@id": "#webpage", "mainEntity": {"@id": "#article"}},
+				{"@type": "Person", "@id": "#sam", "name": "Samwise Gamgee"},
+				{"@type": "NewsArticle", "@id": "#article", "headline": "The Ring Is Destroyed", "author": {"@id": "#sam"}, "datePublished": "3019-03-25", "image": {"@type": "ImageObject", "url": "https://cdn.example.com/ring.png"}}
+			]}</script></head></html>`,
+			Expected: map[string]string{
+				"Author":        "Samwise Gamgee",
+				"DatePublished": "3019-03-25",
+				"Headline":      "The Ring Is Destroyed",
+				"Image":         "https://cdn.example.com/ring.png",
+				"Title":         "The Ring Is Destroyed", // Structured data headline takes precedence over the title element
+				"Type":          "NewsArticle",
+			},
+		},
+		"/product": {
+			Content: `<html><head><script type="application/ld+json">{not json</script><script type="application/ld+json">{"@context": "https://schema.org", "@type": "Product",
+				"name": "Mithril Shirt", "offers": [{"@type": "Offer", "price": 1500, "priceCurrency": "GBP"}], "aggregateRating": {"@type": "AggregateRating", "ratingValue": 4.9, "reviewCount": "21"}
+			}</script></head></html>`,
+			Expected: map[string]string{
+				"Currency":    "GBP",
+				"Headline":    "Mithril Shirt",
+				"Price":       "1500",
+				"Rating":      "4.9",
+				"RatingCount": "21",
+				"Type":        "Product",
+			},
+		},
+		"/authors": {
+			Content: `<html><head><script type="application/ld+json">[
+				{"@context": "https://schema.org", "@type": "WebSite", "name": "Red Book"},
+				{"@context": "https://schema.org", "@type": "BlogPosting", "headline": "There and Back Again", "author": [{"@type": "Person", "name": "Bilbo Baggins"}, "Frodo Baggins"]}
+			]</script></head></html>`,
+			Expected: map[string]string{
+				"Author":   "Bilbo Baggins, Frodo Baggins",
+				"Headline": "There and Back Again",
+				"Type":     "BlogPosting", // Supporting types such as WebSite are skipped
+			},
+		},
+		"/supporting": {
+			Content: `<html><head><script type="application/ld+json">{"@context": "https://schema.org", "@type": "Organization", "name": "Fellowship"}</script></head></html>`,
+			Expected: map[string]string{
+				"Headline": "Fellowship",
+				"Type":     "Organization", // Supporting types are used if the page provides nothing else
+			},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(pages[r.URL.Path].Content))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+
+	for path, page := range pages {
+		link, linkErr := client.GetLink(server.URL + path)
+
+		if linkErr != nil || link.Entity == nil {
+			logErr(fmt.Sprintf("Failed to get JSON-LD entity of %s page: %v", path, linkErr))
+			continue
+		}
+
+		fields := entityFields(link.Entity)
+		fields["Title"] = link.Title
+
+		for field, expected := range page.Expected {
+			if fields[field] == expected {
+				trunk.LogSuccess(fmt.Sprintf("Got JSON-LD %s of %s page", field, path))
+			} else {
+				logErr(fmt.Sprintf("Got JSON-LD %s of %s page as %q rather than %q", field, path, fields[field], expected))
+			}
+		}
+	}
+}
+
+// entityFields will get the fields of the provided entity by name, so they may be compared against expectations
+func entityFields(entity *sauron.Entity) map[string]string {
+	return map[string]string{
+		"Author":        entity.Author,
+		"Currency":      entity.Currency,
+		"DatePublished": entity.DatePublished,
+		"Description":   entity.Description,
+		"Headline":      entity.Headline,
+		"Image":         entity.Image,
+		"Price":         entity.Price,
+		"Rating":        entity.Rating,
+		"RatingCount":   entity.RatingCount,
+		"Type":          entity.Type,
+	}
+}
+
+// MicrodataTest will ensure microdata and RDFa items are parsed with their nested items, and used as the entity of pages without JSON-LD
+func MicrodataTest() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+
+		if r.URL.Path == "/rdfa" {
+			w.Write([]byte(`<html><head><meta property="og:title" content="Council"></head><body>
+				<div vocab="https://schema.org/" typeof="Event" resource="#council">
+					<h1 property="name">Council of Elrond</h1><time property="startDate" datetime="3018-10-25">October 25th</time>
+					<div property="location" typeof="Place"><span property="name">Rivendell</span></div>
+				</div>
+			</body></html>`))
+		} else {
+			w.Write([]byte(`<html><body>
+				<div itemscope itemtype="https://schema.org/Product" itemid="urn:sauron:palantir">
+					<h1 itemprop="name">Palantír</h1>
+					<div itemprop="brand" itemscope itemtype="https://schema.org/Brand"><span itemprop="name">Noldor</span></div>
+					<div itemprop="offers" itemscope itemtype="https://schema.org/Offer"><span itemprop="price" content="99.5">€99.50</span><meta itemprop="priceCurrency" content="EUR"></div>
+					<div itemprop="aggregateRating" itemscope itemtype="https://schema.org/AggregateRating"><span itemprop="ratingValue">4.8</span> from <span itemprop="reviewCount">7</span> reviews</div>
+				</div>
+			</body></html>`))
+		}
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+
+	if link, linkErr := client.GetLink(server.URL + "/microdata"); linkErr != nil || len(link.Items) != 1 || link.Entity == nil {
+		logErr(fmt.Sprintf("Failed to get microdata item: %v %v", link, linkErr))
+	} else if item := link.Items[0]; item.Source != "microdata" || item.ID != "urn:sauron:palantir" || len(item.Types) != 1 || item.Types[0] != "Product" ||
+		item.Get("name") != "Palantír" || // Name of a nested item must not be added to its parent
+		item.GetItem("brand") == nil || item.GetItem("brand").Get("name") != "Noldor" ||
+		item.GetItem("offers") == nil || item.GetItem("offers").Get("price") != "99.5" {
+		logErr(fmt.Sprintf("Got microdata item but does not match expectation: %v", item))
+	} else if fields := entityFields(link.Entity); fields["Type"] != "Product" || fields["Headline"] != "Palantír" ||
+		fields["Currency"] != "EUR" || fields["Price"] != "99.5" || fields["Rating"] != "4.8" || fields["RatingCount"] != "7" {
+		logErr(fmt.Sprintf("Got microdata entity but does not match expectation: %v", fields))
+	} else {
+		trunk.LogSuccess("Got microdata item with nested items")
+	}
+
+	if link, linkErr := client.GetLink(server.URL + "/rdfa"); linkErr != nil || len(link.Items) != 1 || link.Entity == nil { // Open Graph meta in the head is not an item
+		logErr(fmt.Sprintf("Failed to get RDFa item: %v %v", link, linkErr))
+	} else if item := link.Items[0]; item.Source != "rdfa" || item.ID != "#council" || len(item.Types) != 1 || item.Types[0] != "Event" ||
+		item.Get("name") != "Council of Elrond" || item.Get("startDate") != "3018-10-25" ||
+		item.GetItem("location") == nil || item.GetItem("location").Get("name") != "Rivendell" {
+		logErr(fmt.Sprintf("Got RDFa item but does not match expectation: %v", item))
+	} else if link.Entity.Type != "Event" || link.Entity.DatePublished != "3018-10-25" {
+		logErr(fmt.Sprintf("Got RDFa entity but does not match expectation: %v", link.Entity))
+	} else {
+		trunk.LogSuccess("Got RDFa item with nested items")
+	}
+}
+
+// ResolveURLTest will ensure relative URLs are resolved per RFC 3986, against the final URL of the page or its <base href>
+func ResolveURLTest() {
+	base, _ := url.Parse("https://example.com/articles/post?page=1")
+
+	expectations := map[string]string{
+		"../img.png":                "https://example.com/img.png",
+		"img.png":                   "https://example.com/articles/img.png",
+		"/img.png":                  "https://example.com/img.png",
+		"?v=2":                      "https://example.com/articles/post?v=2",
+		"//cdn.example.com/img.png": "https://cdn.example.com/img.png",
+		"http://other.example.com/": "http://other.example.com/",
+		"  ":                        "",
+	}
+
+	for ref, expectedURL := range expectations {
+		if resolved := sauron.ResolveURL(base, ref); resolved == expectedURL {
+			trunk.LogSuccess(fmt.Sprintf("Resolved %q", ref))
+		} else {
+			logErr(fmt.Sprintf("Resolved %q to %q rather than %q", ref, resolved, expectedURL))
+		}
+	}
+
+	page := `<html><head>BASE
+		<meta property="og:image" content="../og.png"><link rel="icon" href="?v=2"><link rel="alternate" type="application/rss+xml" href="//feeds.example.com/rss">
+		<script type="application/ld+json">{"@context": "https://schema.org", "@type": "Article", "headline": "Sauron", "image": "entity.png", "publisher": {"@type": "Organization", "logo": {"@type": "ImageObject", "url": "/logo.png"}}}</script>
+	</head><body><div itemscope itemtype="https://schema.org/Person"><img itemprop="image" src="photo.png"><a itemprop="url" href="../profile">Profile</a></div></body></html>`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect": // Relative URLs must be resolved against the final URL rather than the requested one
+			http.Redirect(w, r, "/articles/post", http.StatusFound)
+		case "/articles/post":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(strings.Replace(page, "BASE", "", 1)))
+		case "/base":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(strings.Replace(page, "BASE", `<base href="https://cdn.example.com/assets/">`, 1)))
+		}
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+
+	pages := map[string]map[string]string{
+		"/redirect": {
+			"Entity image": server.URL + "/articles/entity.png",
+			"Favicon":      server.URL + "/articles/post?v=2",
+			"Feed":         "http://feeds.example.com/rss",
+			"Image":        server.URL + "/og.png",
+			"Item image":   server.URL + "/articles/photo.png",
+			"Item url":     server.URL + "/profile",
+			"Logo":         server.URL + "/logo.png",
+		},
+		"/base": {
+			"Entity image": "https://cdn.example.com/assets/entity.png",
+			"Favicon":      "https://cdn.example.com/assets/?v=2",
+			"Feed":         "https://feeds.example.com/rss",
+			"Image":        "https://cdn.example.com/og.png",
+			"Item image":   "https://cdn.example.com/assets/photo.png",
+			"Item url":     "https://cdn.example.com/profile",
+			"Logo":         "https://cdn.example.com/logo.png",
+		},
+	}
+
+	for path, expectations := range pages {
+		link, linkErr := client.GetLink(server.URL + path)
+
+		if linkErr != nil || link.Entity == nil || len(link.Items) != 1 {
+			logErr(fmt.Sprintf("Failed to get %s page: %v %v", path, link, linkErr))
+			continue
+		}
+
+		var logo string
+
+		if publisher, isNode := link.Entity.Properties["publisher"].(map[string]interface{}); isNode {
+			if logoNode, isLogoNode := publisher["logo"].(map[string]interface{}); isLogoNode {
+				logo, _ = logoNode["url"].(string)
+			}
+		}
+
+		resolved := map[string]string{
+			"Entity image": link.Entity.Image,
+			"Favicon":      link.Favicon,
+			"Feed":         link.Extras["Feed"],
+			"Image":        link.Image,
+			"Item image":   link.Items[0].Get("image"),
+			"Item url":     link.Items[0].Get("url"),
+			"Logo":         logo,
+		}
+
+		for field, expectedURL := range expectations {
+			if resolved[field] == expectedURL {
+				trunk.LogSuccess(fmt.Sprintf("Resolved %s of %s page", field, path))
+			} else {
+				logErr(fmt.Sprintf("Resolved %s of %s page to %q rather than %q", field, path, resolved[field], expectedURL))
+			}
+		}
+	}
+}
+
+// NormalizeTest will ensure URLs are normalized according to our default rules, as well as custom rules
+func NormalizeTest() {
+	expectations := map[string]string{
+		"HTTPS://Example.COM:443?b=2&a=1&utm_source=sauron#section":         "https://example.com/?a=1&b=2",
+		"http://example.com:80/path?fbclid=abc&q=go":                        "http://example.com/path?q=go",
+		"http://example.com:8080/":                                          "http://example.com:8080/",
+		"http://[::1]:80/":                                                  "http://[::1]/",
+		"https://example.com/?b=2&a=1&b=1":                                  "https://example.com/?a=1&b=2&b=1", // Parameters with the same name keep their order
+		"https://example.com/?q=a%20b&UTM_Campaign=sauron":                  "https://example.com/?q=a%20b",
+		"https://example.com/?feature=share&feature=player":                 "https://example.com/?feature=player",
+		"https://example.com/app#!/route":                                   "https://example.com/app#!/route",
+		"https://www.youtube.com/watch?v=YE7VzlLtp-4&feature=youtu.be&pp=x": "https://www.youtube.com/watch?v=YE7VzlLtp-4",
+		"https://m.twitch.tv/towelliee?tt_medium=share&tt_content=channel":  "https://m.twitch.tv/towelliee",
+	}
+
+	for rawURL, expectedURL := range expectations {
+		if normalized, normalizeErr := sauron.Normalize(rawURL); normalizeErr == nil && normalized == expectedURL {
+			trunk.LogSuccess(fmt.Sprintf("Normalized %s", rawURL))
+		} else {
+			logErr(fmt.Sprintf("Normalized %s to %s rather than %s: %v", rawURL, normalized, expectedURL, normalizeErr))
+		}
+	}
+
+	normalizer := &sauron.Normalizer{KeepFragments: true, KeepQueryOrder: true, StripParams: []string{"session"}}
+
+	if normalized, _ := normalizer.NormalizeString("https://example.com/?b=2&session=1&a=1#section"); normalized == "https://example.com/?b=2&a=1#section" {
+		trunk.LogSuccess("Normalized with custom rules")
+	} else {
+		logErr(fmt.Sprintf("Normalized with custom rules to %s", normalized))
+	}
+}
+
+// HeadOnlyBodySizeTest will ensure a head-only parser only requires the document head to fit within the maximum body size
+func HeadOnlyBodySizeTest() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Sauron</title></HEAD><body>" + strings.Repeat("<p>Sauron</p>", 1000) + "</body></html>"))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	client.MaxBodySize = 1000
+
+	if _, linkErr := client.GetLink(server.URL); errors.Is(linkErr, sauron.ErrBodyTooLarge) { // Full page exceeds our maximum
+		trunk.LogSuccess("Refused page exceeding maximum body size")
+	} else {
+		logErr(fmt.Sprintf("Did not refuse page exceeding maximum body size: %v", linkErr))
+	}
+
+	client.HeadOnlyPrimitive = true
+
+	if link, linkErr := client.GetLink(server.URL); linkErr == nil && link.Title == "Sauron" { // Head is within our maximum
+		trunk.LogSuccess("Got head-only link for page exceeding maximum body size")
+	} else {
+		logErr(fmt.Sprintf("Failed to get head-only link for page exceeding maximum body size: %v %v", link, linkErr))
+	}
+}
+
+// OEmbedConsumerTest will ensure oEmbed providers are matched, endpoints are discovered, and responses are merged without overriding the page
+func OEmbedConsumerTest() {
+	provider := sauron.NewOEmbedProvider("Video", "https://oembed.example.com/{format}", "https://video.example.com/watch/*", "https://*.photos.example.com/p/*")
+	literalProvider := sauron.OEmbedProvider{Name: "Video", Schemes: provider.Schemes} // Not created with NewOEmbedProvider, so compiled on each call
+
+	matches := map[string]bool{
+		"https://video.example.com/watch/1":          true,
+		"http://video.example.com/watch/1?t=2":       true, // Scheme of the URL is ignored
+		"https://www.photos.example.com/p/1":         true,
+		"https://photos.example.com/p/1":             false, // Wildcard subdomain requires a subdomain
+		"https://video.example.com.evil.com/watch/1": false,
+		"https://video.example.com/channel/sauron":   false,
+	}
+
+	for rawURL, expected := range matches {
+		u, _ := url.Parse(rawURL)
+
+		if provider.Matches(u) == expected && literalProvider.Matches(u) == expected {
+			trunk.LogSuccess(fmt.Sprintf("Matched oEmbed provider for %s as %v", rawURL, expected))
+		} else {
+			logErr(fmt.Sprintf("Matched oEmbed provider for %s as %v rather than %v", rawURL, provider.Matches(u), expected))
+		}
+	}
+
+	var providerQuery url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host + r.URL.Path {
+		case "video.example.com/watch/1": // Matches our provider, so the discovered endpoint must not be used
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta property="og:title" content="Page Title"><link rel="alternate" type="application/json+oembed" href="https://discovered.example.com/oembed"></head></html>`))
+		case "oembed.example.com/json":
+			providerQuery = r.URL.Query()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"type": "video", "version": "1.0", "title": "Embed Title", "html": "<iframe></iframe>", "width": "640", "height": 360, "thumbnail_url": "https://video.example.com/thumb.jpg", "provider_name": "Video"}`))
+		case "blog.example.com/post": // No provider, so discovered from the page
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><link rel="alternate" type="application/json+oembed" href="/oembed.json?url=post"></head></html>`))
+		case "blog.example.com/oembed.json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"type": "photo", "version": "1.0", "title": "Embed Title", "url": "https://blog.example.com/photo.jpg", "width": 800, "height": 600, "author_name": "Embed Author"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	client.FetchOEmbed = true
+	client.OEmbedProviders = []sauron.OEmbedProvider{provider}
+	client.SetTransport(TestTransport{Server: server})
+
+	if link, linkErr := client.GetLink("https://video.example.com/watch/1"); linkErr != nil || link.OEmbed == nil {
+		logErr(fmt.Sprintf("Failed to get oEmbed from provider: %v %v", link, linkErr))
+	} else if link.Title != "Page Title" || // Page title takes precedence
+		link.Image != "https://video.example.com/thumb.jpg" || // Thumbnail is used as the page has no image
+		link.Extras["EmbedWidth"] != "640" || link.Extras["EmbedHeight"] != "360" || link.Extras["EmbedType"] != "video" ||
+		providerQuery.Get("format") != "json" || providerQuery.Get("url") != "https://video.example.com/watch/1" {
+		logErr(fmt.Sprintf("Got oEmbed from provider but does not match expectation: %v %v", link, providerQuery))
+	} else {
+		trunk.LogSuccess("Got oEmbed from provider")
+	}
+
+	client.Register("blog.example.com", func(doc *goquery.Document, u *url.URL, fullURL string) (*sauron.Link, error) {
+		return &sauron.Link{Extras: map[string]string{"AuthorName": "Parser Author"}, URI: fullURL}, nil
+	})
+
+	if link, linkErr := client.GetLink("https://blog.example.com/post"); linkErr != nil || link.OEmbed == nil {
+		logErr(fmt.Sprintf("Failed to get discovered oEmbed: %v %v", link, linkErr))
+	} else if link.Title != "Embed Title" || // Page has no title, so the embed title is used
+		link.Image != "https://blog.example.com/photo.jpg" || // Photo is used as the page has no image or thumbnail
+		link.Extras["AuthorName"] != "Parser Author" { // Extras set by our parser are not overridden
+		logErr(fmt.Sprintf("Got discovered oEmbed but does not match expectation: %v", link))
+	} else {
+		trunk.LogSuccess("Got discovered oEmbed")
+	}
+}
+
+// OEmbedHandlerTest will ensure our oEmbed handler responds to a local page as expected
+func OEmbedHandlerTest() {
+	pageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		} else if r.URL.Path == "/document.pdf" {
+			w.Header().Set("Content-Type", "application/pdf")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Big Buck Bunny</title><meta property="og:site_name" content="Videos"><meta property="og:video:url" content="https://videos.example/embed/1"><meta property="og:video:type" content="text/html"><meta property="og:video:width" content="1280"><meta property="og:video:height" content="720"></head></html>`))
+	}))
+
+	defer pageServer.Close()
+
+	client := sauron.NewClient()
+	client.SetTransport(TestTransport{Server: pageServer})
+
+	oembedServer := httptest.NewServer(oembed.NewHandler(client))
+	defer oembedServer.Close()
+
+	expectations := map[string]struct {
+		StatusCode int
+		Contains   string
+	}{
+		"/oembed?url=https://videos.example/watch/1&maxwidth=640": {StatusCode: http.StatusOK, Contains: `"type":"video","version":"1.0","width":640`},
+		"/oembed?url=https://videos.example/watch/1&format=xml":   {StatusCode: http.StatusOK, Contains: "<oembed><height>720</height>"},
+		"/oembed?url=https://videos.example/watch/1&format=yaml":  {StatusCode: http.StatusNotImplemented},
+		"/oembed": {StatusCode: http.StatusBadRequest},
+		"/oembed?url=https://videos.example/watch/1&maxwidth=wide": {StatusCode: http.StatusBadRequest},
+		"/oembed?url=https://videos.example/missing":               {StatusCode: http.StatusBadGateway},
+		"/oembed?url=https://videos.example/document.pdf":          {StatusCode: http.StatusUnprocessableEntity},
+		"/oembed?url=http://%5B::1%5D:namedport":                   {StatusCode: http.StatusBadRequest}, // Invalid URL
+	}
+
+	for path, expectation := range expectations {
+		response, getErr := http.Get(oembedServer.URL + path)
+
+		if getErr != nil {
+			logErr(fmt.Sprintf("Failed to get oEmbed response for %s: %v", path, getErr))
+			continue
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		if response.StatusCode == expectation.StatusCode && strings.Contains(string(body), expectation.Contains) {
+			trunk.LogSuccess(fmt.Sprintf("Got expected oEmbed response for %s", path))
+		} else {
+			logErr(fmt.Sprintf("oEmbed response for %s does not match expectation: %d %s", path, response.StatusCode, body))
+		}
+	}
+
+	imageLink := &sauron.Link{Extras: map[string]string{"IsImageLink": "true"}, URI: "https://images.example/1.png"}
+
+	if embed := oembed.FromLink(imageLink, 0, 0); embed.Type == "link" { // Photos require dimensions, which we do not know
+		trunk.LogSuccess("Used link type for image link without dimensions")
+	} else {
+		logErr(fmt.Sprintf("Used %s type for image link without dimensions", embed.Type))
+	}
+
+	imageLink.Extras["ImageWidth"], imageLink.Extras["ImageHeight"] = "1200", "600"
+
+	if embed := oembed.FromLink(imageLink, 600, 0); embed.Type == "photo" && embed.Width == 600 && embed.Height == 300 {
+		trunk.LogSuccess("Used photo type for image link with dimensions")
+	} else {
+		logErr(fmt.Sprintf("Did not use photo type for image link with dimensions: %s %dx%d", embed.Type, embed.Width, embed.Height))
+	}
+
+	players := map[string]string{
+		"javascript:alert(document.cookie)":          "link",
+		"JavaScript:alert(1)":                        "link",
+		"data:text/html,<script>alert(1)</script>":   "link",
+		"//players.example/embed/1":                  "link", // Not absolute
+		"https://players.example/embed/1?autoplay=1": "video",
+	}
+
+	for player, expectedType := range players {
+		playerLink := &sauron.Link{Extras: map[string]string{"TwitterPlayer": player, "Video": player, "VideoType": "text/html"}, Image: player, URI: "https://videos.example/watch/1"}
+		embed := oembed.FromLink(playerLink, 0, 0)
+
+		if embed.Type == expectedType && (expectedType == "video") == strings.Contains(embed.HTML, html.EscapeString(player)) && (embed.ThumbnailURL == "") == (expectedType == "link") {
+			trunk.LogSuccess(fmt.Sprintf("Used %s type for player %s", expectedType, player))
+		} else {
+			logErr(fmt.Sprintf("Used %s type for player %s rather than %s: %v", embed.Type, player, expectedType, embed))
+		}
+	}
+}
+
+// requestIDContextKey is our context key for a request-scoped value, which parsers should receive even when requests are coalesced
+type requestIDContextKey struct{}
+
+// BatchTest will ensure GetLinks returns results in order, fetches duplicate URLs once with their own copy, and respects the per-host concurrency limit
+func BatchTest() {
+	var requests, active, maxActive int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if r.Host == "limited.example.com" { // Track how many requests to this host are in flight at once
+			current := atomic.AddInt32(&active, 1)
+			defer atomic.AddInt32(&active, -1)
+
+			for seen := atomic.LoadInt32(&maxActive); current > seen && !atomic.CompareAndSwapInt32(&maxActive, seen, current); seen = atomic.LoadInt32(&maxActive) {
+			}
+
+			time.Sleep(50 * time.Millisecond) // Ensure our requests overlap
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>" + r.Host + r.URL.Path + "</title></head></html>"))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	client.CoalesceRequests = false // Ensure duplicates are not hidden by request coalescing
+	client.SetTransport(TestTransport{Server: server})
+
+	urls := []string{"https://other.example.com/1", "https://other.example.com/2", "https://other.example.com/1"}
+
+	for i := 0; i < 6; i++ {
+		urls = append(urls, fmt.Sprintf("https://limited.example.com/%d", i))
+	}
+
+	results := client.GetLinks(context.Background(), urls, &sauron.BatchOptions{Concurrency: 8, PerHostConcurrency: 2})
+	inOrder := len(results) == len(urls)
+
+	for index := 0; inOrder && index < len(results); index++ {
+		u, _ := url.Parse(urls[index])
+		inOrder = results[index].URL == urls[index] && results[index].Err == nil && results[index].Link.Title == u.Host+u.Path
+	}
+
+	if inOrder {
+		trunk.LogSuccess("Got batch results in the order requested")
+	} else {
+		logErr(fmt.Sprintf("Did not get batch results in the order requested: %v", results))
+	}
+
+	if fetched := atomic.LoadInt32(&requests); fetched == int32(len(urls)-1) {
+		trunk.LogSuccess("Fetched duplicate batch URL once")
+	} else {
+		logErr(fmt.Sprintf("Fetched %d batch URLs rather than %d", fetched, len(urls)-1))
+	}
+
+	if inOrder {
+		results[0].Link.Extras["Modified"] = "true"
+
+		if results[0].Link != results[2].Link && results[2].Link.Extras["Modified"] == "" {
+			trunk.LogSuccess("Got own copy of link for duplicate batch URL")
+		} else {
+			logErr("Modifying the link of a duplicate batch URL modified the other")
+		}
+	}
+
+	if limit := atomic.LoadInt32(&maxActive); limit == 2 {
+		trunk.LogSuccess("Limited batch requests per host")
+	} else {
+		logErr(fmt.Sprintf("Made %d batch requests to a host at once rather than 2", limit))
+	}
+}
+
+// CoalesceTest will ensure concurrent requests for the same URL only fetch the page once, with parsers receiving context values
+func CoalesceTest() {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(100 * time.Millisecond) // Ensure our requests overlap
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Sauron</title></head></html>"))
+	}))
+
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := sauron.NewClient()
+
+	client.RegisterContext(serverURL.Host, func(ctx context.Context, doc *goquery.Document, u *url.URL, fullPath string) (link *sauron.Link, parseErr error) {
+		if link, parseErr = client.PrimitiveContext(ctx, doc, u, fullPath); parseErr == nil {
+			link.Extras["RequestID"], _ = ctx.Value(requestIDContextKey{}).(string)
+		}
+
+		return
+	})
+
+	var mismatches int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ctx := context.WithValue(context.Background(), requestIDContextKey{}, "sauron")
+
+			if link, linkErr := client.GetLinkContext(ctx, server.URL+"/?utm_source=sauron"); linkErr != nil || link.Extras["RequestID"] != "sauron" {
+				atomic.AddInt32(&mismatches, 1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if atomic.LoadInt32(&mismatches) == 0 && atomic.LoadInt32(&requests) == 1 {
+		trunk.LogSuccess("Coalesced concurrent requests into a single fetch with context values")
+	} else {
+		logErr(fmt.Sprintf("Did not coalesce concurrent requests: %d mismatched links, %d fetches", atomic.LoadInt32(&mismatches), atomic.LoadInt32(&requests)))
+	}
+}
+
+// CacheTest will ensure a Client with a Cache only fetches a page once, including pages which fail
+func CacheTest() {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if r.URL.Path == "/missing" { // Page which fails, so we can test caching of failures
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.URL.Path == "/text" { // Unsupported content, so we can test restoring typed failures from disk
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("Sauron"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Sauron</title></head></html>"))
+	}))
+
+	defer server.Close()
+
+	client := sauron.NewClient()
+	client.Cache = sauron.NewMemoryCache(100)
+	client.CachePolicy.TTL = time.Minute // Our local server is not on a host with its own TTL
+
+	first, firstErr := client.GetLink(server.URL + "/page")
+	second, secondErr := client.GetLink(server.URL + "/page?utm_source=sauron")
+
+	if firstErr == nil && secondErr == nil && second.Title == first.Title && atomic.LoadInt32(&requests) == 1 {
+		trunk.LogSuccess("Got cached link for normalized URL")
+	} else {
+		logErr(fmt.Sprintf("Did not get cached link: %v %v (%d requests)", firstErr, secondErr, atomic.LoadInt32(&requests)))
+	}
+
+	client.GetLink(server.URL + "/missing")
+
+	if _, missingErr := client.GetLink(server.URL + "/missing"); errors.Is(missingErr, sauron.ErrPageNotAccessible) && atomic.LoadInt32(&requests) == 2 {
+		trunk.LogSuccess("Got cached failure for missing page")
+	} else {
+		logErr(fmt.Sprintf("Did not get cached failure: %v (%d requests)", missingErr, atomic.LoadInt32(&requests)))
+	}
+
+	directory, directoryErr := ioutil.TempDir("", "sauron-cache")
+
+	if directoryErr != nil {
+		logErr(fmt.Sprintf("Failed to create directory for disk cache: %v", directoryErr))
+		return
+	}
+
+	defer os.RemoveAll(directory)
+
+	blockingClient := sauron.NewClient() // Refuses our local server, so we can cache a blocked address
+	blockingClient.EnableSafeDialer()
+	blockingClient.Cache, _ = sauron.NewDiskCache(directory)
+	blockingClient.CachePolicy.TTL = time.Minute
+
+	readingClient := sauron.NewClient() // Reads the same directory with its own DiskCache, so entries are restored from disk
+	readingClient.Cache, _ = sauron.NewDiskCache(directory)
+	readingClient.CachePolicy.TTL = time.Minute
+
+	blockingClient.GetLink(server.URL + "/page")
+	readingClient.GetLink(server.URL + "/text")
+
+	_, blockedErr := readingClient.GetLink(server.URL + "/page")  // Would succeed if not cached
+	_, contentErr := blockingClient.GetLink(server.URL + "/text") // Would be blocked if not cached
+
+	if errors.Is(blockedErr, sauron.ErrBlockedAddress) && errors.Is(contentErr, sauron.ErrUnsupportedContent) {
+		trunk.LogSuccess("Restored typed failures from disk cache")
+	} else {
+		logErr(fmt.Sprintf("Did not restore typed failures from disk cache: %v, %v", blockedErr, contentErr))
+	}
+
+	diskCache, _ := sauron.NewDiskCache(directory)
+	diskCache.Set("https://expired.example.com/", &sauron.CacheEntry{Expires: time.Now().Add(-2 * time.Minute), Link: &sauron.Link{}, StaleUntil: time.Now().Add(-time.Minute)})
+	diskCache.Set("https://fresh.example.com/", &sauron.CacheEntry{Expires: time.Now().Add(time.Minute), Link: &sauron.Link{}, StaleUntil: time.Now().Add(time.Minute)})
+
+	if removed, sweepErr := diskCache.Sweep(); sweepErr == nil && removed == 1 {
+		if _, fresh := diskCache.Get("https://fresh.example.com/"); fresh {
+			trunk.LogSuccess("Swept expired entries from disk cache")
+		} else {
+			logErr("Sweeping disk cache removed a fresh entry")
+		}
+	} else {
+		logErr(fmt.Sprintf("Did not sweep expired entries from disk cache: %d removed, %v", removed, sweepErr))
+	}
+
+	nilClient := sauron.NewClient() // Uses a parser which finds no link, with our default policy and its TTLFunc
+	nilClient.Cache = sauron.NewMemoryCache(100)
+	nilClient.SetTransport(TestTransport{Server: server})
+	nilClient.Register("nil.example.com", func(doc *goquery.Document, u *url.URL, fullPath string) (*sauron.Link, error) {
+		return nil, nil
+	})
+
+	if link, linkErr := nilClient.GetLink("https://nil.example.com/page"); link == nil && linkErr == nil {
+		trunk.LogSuccess("Got no link from cached parser which found none")
+	} else {
+		logErr(fmt.Sprintf("Did not get no link from cached parser which found none: %v %v", link, linkErr))
+	}
+}
+
+// ServerTest will ensure our server Handler requires API keys, serves batches and reports readiness
+func ServerTest() {
+	pageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" { // Page which fails, so we can test errors within a batch
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Sauron</title></head></html>"))
+	}))
+
+	defer pageServer.Close()
+
+	client := sauron.NewClient()
+	client.SetTransport(TestTransport{Server: pageServer})
+
+	handler := server.NewHandler(client, server.Config{
+		APIKeys:        []string{"secret"},
+		BatchTimeout:   5 * time.Second,
+		LinkTimeout:    5 * time.Second,
+		MaxBatch:       2,
+		MaxConcurrency: 4,
+		MaxPerHost:     2,
+	})
+
+	apiServer := httptest.NewServer(handler)
+	defer apiServer.Close()
+
+	requests := []struct {
+		Body       string
+		Contains   string
+		Key        string
+		Method     string
+		Path       string
+		StatusCode int
+	}{
+		{Method: http.MethodGet, Path: "/v1/link?url=https://example.com", StatusCode: http.StatusUnauthorized},
+		{Method: http.MethodGet, Path: "/v1/link?url=https://example.com", Key: "wrong", StatusCode: http.StatusUnauthorized},
+		{Method: http.MethodGet, Path: "/v1/link?url=https://example.com", Key: "secret", StatusCode: http.StatusOK, Contains: `"Title":"Sauron"`},
+		{Method: http.MethodGet, Path: "/v1/link?url=https://example.com/missing", Key: "secret", StatusCode: http.StatusBadGateway},
+		{Method: http.MethodPost, Path: "/v1/links", Key: "secret", Body: `{"urls": ["https://example.com", "https://example.com/missing"]}`, StatusCode: http.StatusOK, Contains: `"status":200,"url":"https://example.com"},{"error":"Page not accessible`},
+		{Method: http.MethodPost, Path: "/v1/links", Key: "secret", Body: `{"urls": ["https://a.example", "https://b.example", "https://c.example"]}`, StatusCode: http.StatusRequestEntityTooLarge},
+		{Method: http.MethodGet, Path: "/healthz", StatusCode: http.StatusOK},
+		{Method: http.MethodGet, Path: "/readyz", StatusCode: http.StatusOK},
+	}
+
+	for _, expectation := range requests {
+		request, _ := http.NewRequest(expectation.Method, apiServer.URL+expectation.Path, strings.NewReader(expectation.Body))
+
+		if expectation.Key != "" {
+			request.Header.Set("Authorization", "Bearer "+expectation.Key)
+		}
+
+		response, requestErr := http.DefaultClient.Do(request)
+
+		if requestErr != nil {
+			logErr(fmt.Sprintf("Failed to request %s %s from server: %v", expectation.Method, expectation.Path, requestErr))
+			continue
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		if response.StatusCode == expectation.StatusCode && strings.Contains(string(body), expectation.Contains) {
+			trunk.LogSuccess(fmt.Sprintf("Got expected server response for %s %s", expectation.Method, expectation.Path))
+		} else {
+			logErr(fmt.Sprintf("Server response for %s %s does not match expectation: %d %s", expectation.Method, expectation.Path, response.StatusCode, body))
+		}
+	}
+
+	handler.SetReady(false) // Shutting down
+
+	if response, getErr := http.Get(apiServer.URL + "/readyz"); getErr == nil && response.StatusCode == http.StatusServiceUnavailable {
+		response.Body.Close()
+		trunk.LogSuccess("Server reported not ready once shutting down")
+	} else {
+		logErr(fmt.Sprintf("Server did not report not ready once shutting down: %v %v", response, getErr))
+	}
+
+	defaultsServer := httptest.NewServer(server.NewHandler(client, server.Config{})) // Timeouts which are not set must not expire immediately
+	defer defaultsServer.Close()
+
+	if response, postErr := http.Post(defaultsServer.URL+"/v1/links", "application/json", strings.NewReader(`{"urls": ["https://example.com"]}`)); postErr != nil {
+		logErr(fmt.Sprintf("Failed to request batch from server with default config: %v", postErr))
+	} else if body, _ := ioutil.ReadAll(response.Body); response.StatusCode != http.StatusOK || !strings.Contains(string(body), `"status":200`) {
+		response.Body.Close()
+		logErr(fmt.Sprintf("Server with default config did not get batch: %d %s", response.StatusCode, body))
+	} else {
+		response.Body.Close()
+		trunk.LogSuccess("Got batch from server with default config")
+	}
+
+	if response, getErr := http.Get(defaultsServer.URL + "/v1/link?url=https://example.com"); getErr == nil && response.StatusCode == http.StatusOK {
+		response.Body.Close()
+		trunk.LogSuccess("Got link from server with default config")
+	} else {
+		logErr(fmt.Sprintf("Server with default config did not get link: %v %v", response, getErr))
+	}
+
+	if status := server.StatusForError(&sauron.RequestError{Err: context.Canceled}); status == 499 { // Client disconnecting is not a failure of the page
+		trunk.LogSuccess("Used 499 status for cancelled request")
+	} else {
+		logErr(fmt.Sprintf("Used %d status for cancelled request", status))
+	}
+}
+
+// CLITest will run the sauron command against a local server, checking URLs are read from arguments and stdin and written as a table or Markdown
+func CLITest() {
+	pageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Sauron | ` + strings.TrimPrefix(r.URL.Path, "/") + `</title><meta name="description" content="` + strings.Repeat("Eye ", 25) + `"></head></html>`))
+	}))
+
+	defer pageServer.Close()
+
+	buildDir, dirErr := ioutil.TempDir("", "sauron")
+
+	if dirErr != nil {
+		logErr(fmt.Sprintf("Failed to create directory for the sauron command: %v", dirErr))
+		return
+	}
+
+	defer os.RemoveAll(buildDir)
+
+	command := filepath.Join(buildDir, "sauron")
+
+	if output, buildErr := exec.Command("go", "build", "-o", command, "github.com/TryStreambits/sauron/cmd/sauron").CombinedOutput(); buildErr != nil {
+		logErr(fmt.Sprintf("Failed to build the sauron command: %v %s", buildErr, output))
+		return
+	}
+
+	run := func(stdin string, args ...string) (output string, exitCode int) {
+		cmd := exec.Command(command, args...)
+		cmd.Stdin = strings.NewReader(stdin)
+		outputBytes, _ := cmd.Output()
+		return string(outputBytes), cmd.ProcessState.ExitCode()
+	}
+
+	stdin := "# Comments and blank lines are ignored\n\n" + pageServer.URL + "/stdin\n  " + pageServer.URL + "/padded  \n"
+
+	if output, exitCode := run(stdin, "-format", "table", pageServer.URL+"/argument", "-"); exitCode != 0 {
+		logErr(fmt.Sprintf("sauron command exited with %d for table: %s", exitCode, output))
+	} else if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) != 4 || !strings.HasPrefix(lines[0], "URL") ||
+		!strings.HasPrefix(lines[1], pageServer.URL+"/argument ") || !strings.HasPrefix(lines[2], pageServer.URL+"/stdin ") || !strings.HasPrefix(lines[3], pageServer.URL+"/padded ") || // Arguments in order, with stdin in place of -
+		!strings.Contains(lines[1], "Sauron | argument") || !strings.Contains(lines[1], strings.Repeat("Eye ", 14)+"Eye…") { // Long descriptions are truncated
+		logErr(fmt.Sprintf("sauron command table does not match expectation:\n%s", output))
+	} else {
+		trunk.LogSuccess("Got table from sauron command with URLs from arguments and stdin")
+	}
+
+	if output, exitCode := run("", "-format", "markdown", pageServer.URL+"/markdown", pageServer.URL+"/missing"); exitCode != 1 { // Failed URLs are reported via our exit status
+		logErr(fmt.Sprintf("sauron command exited with %d for Markdown with a failed URL: %s", exitCode, output))
+	} else if !strings.Contains(output, "## "+pageServer.URL+"/markdown\n\n| Field | Value |\n| --- | --- |\n| Title | Sauron \\| markdown |\n") || // Pipes are escaped
+		!strings.Contains(output, "| Description | "+strings.TrimSpace(strings.Repeat("Eye ", 25))+" |\n") ||
+		!strings.Contains(output, "## "+pageServer.URL+"/missing\n\n| Field | Value |\n| --- | --- |\n| Error | Page not accessible") {
+		logErr(fmt.Sprintf("sauron command Markdown does not match expectation:\n%s", output))
+	} else {
+		trunk.LogSuccess("Got Markdown from sauron command with a failed URL")
+	}
+}
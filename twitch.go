@@ -4,17 +4,18 @@ package sauron
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 )
 
+// maxGqlSize is the maximum number of bytes read from a Twitch GQL response
+const maxGqlSize = 1 << 20 // 1 MiB
+
 var ChannelRequestJSON string
 var ClipRequestJSON string
 
@@ -25,7 +26,24 @@ func init() {
 
 // Twitch is our internal Twitch parser
 // This parser will leverage Twitch's GQL (used during info fetching for page content generation) to get various JSON data for the request
-func Twitch(_doc *goquery.Document, url *url.URL, fullURL string) (link *Link, parserErr error) {
+func Twitch(doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return DefaultClient.TwitchContext(context.Background(), doc, url, fullURL)
+}
+
+// TwitchContext is our internal Twitch parser, using the provided context for the GQL request
+func TwitchContext(ctx context.Context, doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return DefaultClient.TwitchContext(ctx, doc, url, fullURL)
+}
+
+// Twitch is our internal Twitch parser
+// This parser will leverage Twitch's GQL (used during info fetching for page content generation) to get various JSON data for the request
+func (c *Client) Twitch(doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return c.TwitchContext(context.Background(), doc, url, fullURL)
+}
+
+// TwitchContext is our internal Twitch parser
+// This parser will leverage Twitch's GQL to get various JSON data for the request, using the provided context and our HTTP client for the GQL request
+func (c *Client) TwitchContext(ctx context.Context, _doc *goquery.Document, url *url.URL, fullURL string) (link *Link, parserErr error) {
 	link = &Link{
 		Description: "",                      // Create an empty description for now
 		Favicon:     "",                      // Create an empty favicon for now
@@ -50,35 +68,40 @@ func Twitch(_doc *goquery.Document, url *url.URL, fullURL string) (link *Link, p
 		link.Extras["IsClip"] = "false"
 	}
 
-	request, requestNewErr := http.NewRequest("POST", "https://gql.twitch.tv/gql", bytes.NewBuffer([]byte(content)))
+	request, requestNewErr := http.NewRequestWithContext(ctx, "POST", "https://gql.twitch.tv/gql", bytes.NewBuffer([]byte(content)))
 
 	if requestNewErr != nil {
 		parserErr = requestNewErr
 		return
 	}
 
-	request.Header.Set("Accept-Language", RequestLanguage)            // Prefer English
+	request.Header.Set("Accept-Language", c.RequestLanguage)          // Prefer English
 	request.Header.Set("Client-Id", "kimne78kx3ncx6brgo4mv6wki5h1ko") // Generic Twitch Client-Id
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Trident/7.0; rv:11.0) like Gecko") // Fake old browser
 	request.Header.Set("X-Device-Id", "derpnotreal")
 
-	client := http.Client{
-		Timeout: time.Second * 15, // 15 seconds
-	}
-
-	response, getErr := client.Do(request)
+	response, getErr := c.HTTPClient.Do(request)
 
 	if getErr != nil {
-		parserErr = getErr
+		parserErr = &RequestError{Err: getErr, URL: request.URL.String()}
 		return
 	}
 
 	defer response.Body.Close()
-	responseContent, _ := ioutil.ReadAll(response.Body) // Read the body contents
 
 	if response.StatusCode != 200 { // Status not OK
-		parserErr = errors.New(string(responseContent))
+		parserErr = &HTTPStatusError{StatusCode: response.StatusCode, URL: request.URL.String()}
+		return
+	}
+
+	responseContent, readErr := readPage(response.Body, maxGqlSize, false) // Read the body contents
+
+	if readErr == ErrBodyTooLarge {
+		parserErr = readErr
+		return
+	} else if readErr != nil {
+		parserErr = &RequestError{Err: readErr, URL: request.URL.String()}
 		return
 	}
 
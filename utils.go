@@ -1,29 +1,189 @@
 package sauron
 
 import (
+	"bytes"
+	"context"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"io"
 	"net/http"
 	"net/url"
-	"time"
+	"strings"
+	"unicode/utf8"
 )
 
 // This file contains various utilities for Sauron
 
+// charsetSniffLength is the number of bytes of a page used for determining its character set
+const charsetSniffLength = 64 * 1024
+
+// headEndMarker is the beginning of the closing head tag, used to stop reading pages for parsers which only require the document head
+var headEndMarker = []byte("</head")
+
+// httpClientContextKey is our context key for the HTTP client of the Client handling a request
+type httpClientContextKey struct{}
+
+// BaseURL will get the URL which relative URLs in the document should be resolved against
+// This is the document's <base href> if it has one, resolved against the final URL of the page after any redirects.
+// If the document was not fetched by a Client, the provided URL is used in place of the final URL.
+func BaseURL(doc *goquery.Document, u *url.URL) *url.URL {
+	base := u
+
+	if doc.Url != nil { // If we have the final URL of the page
+		base = doc.Url
+	}
+
+	if baseHref := strings.TrimSpace(doc.Find("base[href]").First().AttrOr("href", "")); baseHref != "" { // If the page declares a base URL
+		if baseURL, parseErr := base.Parse(baseHref); parseErr == nil {
+			base = baseURL
+		}
+	}
+
+	return base
+}
+
+// decodePage will convert the provided page content to UTF-8
+// The character set is determined from a byte order mark, the charset of the provided Content-Type, or a meta charset / http-equiv element.
+// Any remaining invalid UTF-8 is replaced, so content is always valid UTF-8.
+func decodePage(pageContent []byte, contentType string) ([]byte, error) {
+	sniffContent := pageContent
+
+	if len(sniffContent) > charsetSniffLength { // Only use the beginning of the page for determining the character set
+		sniffContent = sniffContent[:charsetSniffLength]
+	}
+
+	pageEncoding, _, _ := charset.DetermineEncoding(sniffContent, contentType)
+
+	if pageEncoding != encoding.Nop { // Not already UTF-8
+		decoded, decodeErr := pageEncoding.NewDecoder().Bytes(pageContent)
+
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+
+		pageContent = decoded
+	}
+
+	pageContent = bytes.TrimPrefix(pageContent, []byte("\xef\xbb\xbf")) // Trim any byte order mark, which would otherwise be treated as body text
+
+	if !utf8.Valid(pageContent) { // If we still have invalid UTF-8, such as a page incorrectly declaring itself as UTF-8
+		pageContent = bytes.ToValidUTF8(pageContent, []byte(string(utf8.RuneError)))
+	}
+
+	return pageContent, nil
+}
+
+// documentBaseURL will get the base URL of the document if it has its final URL set, such as when fetched by a Client, otherwise nil
+func documentBaseURL(doc *goquery.Document) *url.URL {
+	if doc.Url == nil { // Not fetched by a Client, so we have nothing to resolve against
+		return nil
+	}
+
+	return BaseURL(doc, doc.Url)
+}
+
+// HTTPClientFromContext will get the HTTP client of the Client handling the request from the context provided to a ContextLinkParser
+// Parsers should use this client for any secondary requests so they share the same transport, such as a proxy or test RoundTripper.
+// If the context was not provided by a Client, the HTTP client of the DefaultClient is returned.
+func HTTPClientFromContext(ctx context.Context) *http.Client {
+	if client, hasClient := ctx.Value(httpClientContextKey{}).(*http.Client); hasClient && client != nil {
+		return client
+	}
+
+	return DefaultClient.HTTPClient
+}
+
 // NewHTTPClient will create a new request-specific client, with our defined user agent, for the purposes of page fetching.
+// This uses the HTTP client and request headers of the DefaultClient.
 // If successful, it will return both the client and the request for use
 func NewHTTPClient(u *url.URL) (client http.Client, request http.Request) {
-	client = http.Client{
-		Timeout: time.Second * 15, // 15 seconds
+	client = *DefaultClient.HTTPClient
+	request = *DefaultClient.NewRequest(context.Background(), u)
+	return
+}
+
+// lowerASCII will get a copy of the provided content with only ASCII letters lowercased, so indexes in the copy match the original
+func lowerASCII(content []byte) []byte {
+	lowered := make([]byte, len(content))
+
+	for index, character := range content {
+		if 'A' <= character && character <= 'Z' {
+			character += 'a' - 'A'
+		}
+
+		lowered[index] = character
+	}
+
+	return lowered
+}
+
+// readPage will read the provided page body, up to the maximum size
+// If maxSize is exceeded, ErrBodyTooLarge is returned. A maxSize of 0 or less disables the limit.
+// If headOnly is true, reading stops once the closing head tag is reached and the content is cut at the tag, so only the head must fit within maxSize.
+func readPage(body io.Reader, maxSize int64, headOnly bool) (pageContent []byte, readErr error) {
+	if maxSize > 0 { // If we have a maximum size, read one byte more so we know if it has been exceeded
+		body = io.LimitReader(body, maxSize+1)
+	}
+
+	var buffer bytes.Buffer
+	chunk := make([]byte, 32*1024)
+
+	for {
+		read, chunkErr := body.Read(chunk)
+		searchStart := buffer.Len() - len(headEndMarker) // Search from slightly before this chunk in case the marker spans chunks
+		buffer.Write(chunk[:read])
+
+		if headOnly && read > 0 { // If we should stop at the end of the head
+			if searchStart < 0 {
+				searchStart = 0
+			}
+
+			if index := bytes.Index(lowerASCII(buffer.Bytes()[searchStart:]), headEndMarker); index != -1 { // Reached the end of our head
+				buffer.Truncate(searchStart + index) // Cut at the closing head tag, as the rest of the page is not required
+				break
+			}
+		}
+
+		if maxSize > 0 && int64(buffer.Len()) > maxSize { // Exceeded our maximum size
+			return nil, ErrBodyTooLarge
+		}
+
+		if chunkErr == io.EOF { // Finished reading
+			break
+		} else if chunkErr != nil {
+			return nil, chunkErr
+		}
 	}
 
-	var requestHeaders = make(http.Header)
-	requestHeaders.Set("Accept-Language", RequestLanguage) // Prefer English
-	requestHeaders.Set("User-Agent", UserAgent)
+	pageContent = buffer.Bytes()
+	return
+}
 
-	request = http.Request{
-		Header: requestHeaders,
-		Method: "GET",
-		URL:    u,
+// redirectChain will get the redirects followed to get the provided response, in the order they were followed
+func redirectChain(response *http.Response) (redirects []Redirect) {
+	for redirect := response.Request.Response; redirect != nil; redirect = redirect.Request.Response { // Walk back from our final request
+		redirects = append([]Redirect{{StatusCode: redirect.StatusCode, URL: redirect.Request.URL.String()}}, redirects...)
 	}
 
 	return
 }
+
+// ResolveURL will resolve the provided URL reference against the base URL per RFC 3986
+// This handles references such as ../img.png, ?v=2 and //cdn.example.com/img.png. Absolute URLs are returned as is.
+// An empty string is returned if the reference is empty or invalid.
+func ResolveURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+
+	if ref == "" { // No reference
+		return ""
+	}
+
+	resolved, parseErr := base.Parse(ref)
+
+	if parseErr != nil { // Not a valid URL
+		return ""
+	}
+
+	return resolved.String()
+}
@@ -1,28 +1,34 @@
 package sauron
 
 import (
+	"context"
 	"github.com/PuerkitoBio/goquery"
 	"net/url"
 	"strings"
 )
 
-// YoutubeQueriesToExtras is query info to extra metadata
-var YoutubeQueriesToExtras map[string]string
+// Youtube is our internal Youtube parser, using the DefaultClient
+// This parser will get page information as well as add extra metadata for various shorteners and form factors
+func Youtube(doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return DefaultClient.YoutubeContext(context.Background(), doc, url, fullURL)
+}
 
-func init() {
-	YoutubeQueriesToExtras = map[string]string{
-		"i":    "Index",
-		"list": "Playlist",
-		"t":    "Time",
-		"v":    "Video",
-	}
+// YoutubeContext is our internal Youtube parser, using the DefaultClient and the provided context for any secondary requests
+func YoutubeContext(ctx context.Context, doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return DefaultClient.YoutubeContext(ctx, doc, url, fullURL)
 }
 
 // Youtube is our internal Youtube parser
 // This parser will get page information as well as add extra metadata for various shorteners and form factors
-func Youtube(doc *goquery.Document, url *url.URL, fullURL string) (link *Link, parserErr error) {
-	link, parserErr = Primitive(doc, url, fullURL)            // First get our link information from Primitive
-	link.Title = strings.TrimSuffix(link.Title, " - YouTube") // Strip - Youtube from the Title
+func (c *Client) Youtube(doc *goquery.Document, url *url.URL, fullURL string) (*Link, error) {
+	return c.YoutubeContext(context.Background(), doc, url, fullURL)
+}
+
+// YoutubeContext is our internal Youtube parser, using the provided context for any secondary requests made by Primitive
+// This parser will get page information as well as add extra metadata for various shorteners and form factors
+func (c *Client) YoutubeContext(ctx context.Context, doc *goquery.Document, url *url.URL, fullURL string) (link *Link, parserErr error) {
+	link, parserErr = c.PrimitiveContext(ctx, doc, url, fullURL) // First get our link information from Primitive
+	link.Title = strings.TrimSuffix(link.Title, " - YouTube")    // Strip - Youtube from the Title
 
 	if link.Title == "" { // If this has no title
 		link.Title = doc.Find("meta[itemprop=\"name\"]").AttrOr("content", "YouTube")
@@ -34,7 +40,7 @@ func Youtube(doc *goquery.Document, url *url.URL, fullURL string) (link *Link, p
 		for queryParam := range url.Query() { // For each map of query params to values
 			queryVal := url.Query().Get(queryParam) // Get the first value
 
-			if extrasType, queryTypeExists := YoutubeQueriesToExtras[queryParam]; queryTypeExists { // If this query param exists
+			if extrasType, queryTypeExists := c.YoutubeQueriesToExtras[queryParam]; queryTypeExists { // If this query param exists
 				link.Extras[extrasType] = queryVal
 			}
 		}